@@ -10,9 +10,15 @@ import (
 	"time"
 
 	"homework/internal/audit"
+	"homework/internal/auth"
 	"homework/internal/cache"
 	"homework/internal/config"
+	"homework/internal/cursor"
 	"homework/internal/db"
+	"homework/internal/expiry"
+	"homework/internal/grpcserver"
+	"homework/internal/idempotency"
+	"homework/internal/packaging"
 	"homework/internal/repository"
 	"homework/internal/server"
 	"homework/internal/service"
@@ -44,12 +50,14 @@ func main() {
 
 	processorConfigs := []audit.ProcessorConfig{
 		{
+			Name:        "db",
 			Processor:   &audit.DBProcessor{Db: database},
 			BatchSize:   5,
 			Timeout:     500 * time.Millisecond,
 			ChannelSize: 50,
 		},
 		{
+			Name:        "stdout",
 			Processor:   &audit.StdoutProcessor{Filter: cfg.FilterWord},
 			BatchSize:   3,
 			Timeout:     2 * time.Second,
@@ -62,34 +70,146 @@ func main() {
 	activeCache := cache.NewActiveOrdersCache()
 
 	historyCache := cache.NewHistoryCache()
-	if err := historyCache.Refresh(repo); err != nil {
+	if err := historyCache.Refresh(context.Background(), repo); err != nil {
 		log.Fatalf("Error refreshing history cache: %v", err)
 	}
 
-	orderService := service.NewOrderService(repo, activeCache, historyCache)
-	orderWrapper := wrapper.NewOrderWrapper(orderService)
+	recipientService := service.NewRecipientService(repository.NewPostgresRecipientRepository(database),
+		cfg.Timeouts, cfg.AutoCreateRecipients)
+	pointService := service.NewPickupPointService(repository.NewPostgresPickupPointRepository(database),
+		cfg.Timeouts, cfg.DefaultPickupPoint)
+	orderService := service.NewOrderService(repo, activeCache, historyCache, cfg.Timeouts,
+		service.NewValidator(cfg.MaxStorageHorizon, packaging.Default), recipientService, pointService)
+	orderWrapper := wrapper.NewOrderWrapper(orderService, recipientService, pointService)
 
-	if err := orderWrapper.RefreshActiveOrders(); err != nil {
+	if err := orderWrapper.RefreshActiveOrders(context.Background()); err != nil {
 		log.Fatalf("Error refreshing active cache: %v", err)
 	}
-	srv := server.NewServer(orderWrapper, cfg, auditPool)
+	users, err := loadUsers(cfg)
+	if err != nil {
+		log.Fatalf("Error loading users: %v", err)
+	}
+	tokens, err := newTokenIssuer(cfg, repository.NewPostgresRevokedTokenRepository(database))
+	if err != nil {
+		log.Fatalf("Error setting up token issuer: %v", err)
+	}
+	authn := &auth.Authenticator{
+		Users:   users,
+		Tokens:  tokens,
+		APIKeys: auth.NewAPIKeys(repository.NewPostgresAPIKeyRepository(database)),
+	}
+	idemStore := repository.NewPostgresIdempotencyRepository(database)
+	cursors, err := newCursorCodec(cfg)
+	if err != nil {
+		log.Fatalf("Error setting up cursor codec: %v", err)
+	}
+	srv := server.NewServer(orderWrapper, cfg, auditPool, authn, idemStore, cursors)
+	grpcSrv := grpcserver.NewServer(orderWrapper, cfg, auditPool, authn, cursors)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	go historyCache.StartAutoRefresh(ctx, repo, 5*time.Minute)
+	// The pool is stopped explicitly during shutdown, after the servers
+	// have drained, so that records from in-flight requests are flushed.
+	auditPool.Start(context.Background())
+
+	refreshDone := runUntilDone(func() { historyCache.StartAutoRefresh(ctx, repo, 5*time.Minute) })
+	purgeDone := runUntilDone(func() { idempotency.StartPurge(ctx, idemStore, time.Hour) })
+
+	expiryScheduler := expiry.NewScheduler(orderWrapper, auditPool, cfg.Expiry.Interval, cfg.Expiry.BatchSize)
+	expiryDone := runUntilDone(func() { expiryScheduler.Start(ctx) })
 
 	taskProc := taskprocessor.NewTaskProcessor(taskRepo, *prod, cfg.KafkaTopic, 1*time.Second, 10)
-	go taskProc.Start(ctx)
+	processorDone := runUntilDone(func() { taskProc.Start(ctx) })
+
+	consumerDone := runUntilDone(func() {
+		kafka.StartSaramaConsumer(ctx, cfg.KafkaConfig, cfg.KafkaBrokers, cfg.KafkaGroupID, []string{cfg.KafkaTopic})
+	})
+
+	serveErr := make(chan error, 2)
+	go func() { serveErr <- grpcSrv.Run() }()
+	go func() { serveErr <- srv.Run() }()
+
+	select {
+	case <-ctx.Done():
+		log.Printf("Shutdown signal received")
+	case err := <-serveErr:
+		log.Printf("Server stopped with error: %v", err)
+		stop()
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down HTTP server: %v", err)
+	}
+	grpcSrv.Shutdown(shutdownCtx)
+	// The scheduler audits the orders it expires, so it has to stop before
+	// the pool does; all of these must stop before the database is closed.
+	waitDone(shutdownCtx, expiryDone, "expiry scheduler")
+	waitDone(shutdownCtx, refreshDone, "history cache refresh")
+	waitDone(shutdownCtx, purgeDone, "idempotency purge")
+	if err := auditPool.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error flushing audit pool: %v", err)
+	}
+	waitDone(shutdownCtx, processorDone, "task processor")
+	waitDone(shutdownCtx, consumerDone, "kafka consumer")
+	log.Printf("Shutdown complete")
+}
+
+func loadUsers(cfg *config.Config) (*auth.UserStore, error) {
+	if cfg.UsersFile != "" {
+		return auth.LoadUserStore(cfg.UsersFile)
+	}
+	hash, err := auth.HashPassword(cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewUserStore(auth.User{Name: cfg.Username, Role: auth.RoleAdmin, PasswordHash: hash})
+}
 
-	cont, cancelF := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer cancelF()
+func newTokenIssuer(cfg *config.Config, denylist auth.Denylist) (*auth.TokenIssuer, error) {
+	var key []byte
+	var err error
+	if cfg.JWT.KeyFile != "" {
+		key, err = auth.LoadSigningKey(cfg.JWT.KeyFile)
+	} else {
+		log.Printf("APP_JWT_KEY_FILE is not set, tokens will not survive a restart")
+		key, err = auth.RandomSigningKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewTokenIssuer(key, cfg.JWT.Issuer, cfg.JWT.TTL, denylist)
+}
 
-	go kafka.StartSaramaConsumer(cont, cfg.KafkaConfig, cfg.KafkaBrokers, cfg.KafkaGroupID, []string{cfg.KafkaTopic})
+func newCursorCodec(cfg *config.Config) (*cursor.Codec, error) {
+	if cfg.CursorKeyFile == "" {
+		log.Printf("APP_CURSOR_KEY_FILE is not set, pagination cursors will not survive a restart")
+		key, err := auth.RandomSigningKey()
+		return cursor.NewCodec(key), err
+	}
+	key, err := auth.LoadSigningKey(cfg.CursorKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return cursor.NewCodec(key), nil
+}
 
-	go historyCache.StartAutoRefresh(ctx, repo, 5*time.Minute)
+func runUntilDone(fn func()) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	return done
+}
 
-	if err := srv.Run(); err != nil {
-		log.Fatalf("Server stopped with error: %v", err)
+func waitDone(ctx context.Context, done <-chan struct{}, name string) {
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Timed out waiting for %s to stop", name)
 	}
 }
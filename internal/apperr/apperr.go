@@ -0,0 +1,107 @@
+// Package apperr is the error model shared by the repository, service and
+// transport layers. Domain failures are reported as *Error values with a
+// Kind, which decides the HTTP status and gRPC code, and a stable Code that
+// clients can match on.
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Kind string
+
+const (
+	NotFound          Kind = "not_found"
+	AlreadyExists     Kind = "already_exists"
+	InvalidTransition Kind = "invalid_transition"
+	Validation        Kind = "validation"
+	Conflict          Kind = "conflict"
+	// Precondition reports a failed optimistic concurrency check.
+	Precondition Kind = "precondition"
+	Timeout      Kind = "timeout"
+	Canceled     Kind = "canceled"
+	Internal     Kind = "internal"
+)
+
+// FieldError describes one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Field builds a FieldError with a formatted message.
+func Field(name, format string, args ...any) FieldError {
+	return FieldError{Field: name, Message: fmt.Sprintf(format, args...)}
+}
+
+// Error is an application error. Sentinels such as repository.ErrOrderNotFound
+// are *Error values, so they can be wrapped with fmt.Errorf and still be found
+// with errors.Is and errors.As.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// NewValidation reports every invalid field of a request at once.
+func NewValidation(fields ...FieldError) *Error {
+	return &Error{Kind: Validation, Code: "validation_failed", Message: "validation failed", Fields: fields}
+}
+
+func (e *Error) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return e.Message + ": " + strings.Join(parts, "; ")
+}
+
+var (
+	errTimeout  = New(Timeout, "deadline_exceeded", "deadline exceeded")
+	errCanceled = New(Canceled, "canceled", "request canceled")
+	errInternal = New(Internal, "internal", "internal error")
+)
+
+// From returns the *Error in err's chain. Context errors are reported as
+// Timeout and Canceled; anything else is Internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return errTimeout
+	case errors.Is(err, context.Canceled):
+		return errCanceled
+	default:
+		return errInternal
+	}
+}
+
+// KindOf is shorthand for From(err).Kind.
+func KindOf(err error) Kind {
+	return From(err).Kind
+}
+
+// Detail is the message safe to show a client: the full error chain for
+// domain errors and a generic text for internal ones, which may carry driver
+// details such as SQL errors.
+func Detail(err error) string {
+	e := From(err)
+	if e.Kind == Internal {
+		return e.Message
+	}
+	return err.Error()
+}
@@ -0,0 +1,75 @@
+package apperr_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"homework/internal/apperr"
+)
+
+var errNotFound = apperr.New(apperr.NotFound, "order_not_found", "order not found")
+
+func TestNewProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"wrapped sentinel", fmt.Errorf("order 42: %w", errNotFound), http.StatusNotFound, "order_not_found", "order 42: order not found"},
+		{"deadline", fmt.Errorf("get: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "deadline_exceeded", "get: context deadline exceeded"},
+		{"internal hides detail", errors.New("pq: duplicate key"), http.StatusInternalServerError, "internal", "internal error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := apperr.NewProblem(tt.err, "/orders/42")
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, tt.detail, p.Detail)
+		})
+	}
+}
+
+func TestWriteProblemValidation(t *testing.T) {
+	err := apperr.NewValidation(apperr.Field("weight", "must be positive"), apperr.Field("id", "is required"))
+	rec := httptest.NewRecorder()
+	apperr.WriteProblem(rec, httptest.NewRequest(http.MethodPost, "/orders", nil), err)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, apperr.ProblemContentType, rec.Header().Get("Content-Type"))
+	var p apperr.Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	assert.Equal(t, "validation_failed", p.Code)
+	assert.Equal(t, "/orders", p.Instance)
+	assert.Len(t, p.Errors, 2)
+}
+
+func TestGRPCStatus(t *testing.T) {
+	st := status.Convert(apperr.GRPCStatus(apperr.NewValidation(apperr.Field("cost", "must be positive"))))
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+
+	var reason string
+	var violations int
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			reason = d.GetReason()
+		case *errdetails.BadRequest:
+			violations = len(d.GetFieldViolations())
+		}
+	}
+	assert.Equal(t, "validation_failed", reason)
+	assert.Equal(t, 1, violations)
+}
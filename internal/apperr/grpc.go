@@ -0,0 +1,49 @@
+package apperr
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+const errorDomain = "pickup-orders"
+
+var grpcCode = map[Kind]codes.Code{
+	NotFound:          codes.NotFound,
+	AlreadyExists:     codes.AlreadyExists,
+	InvalidTransition: codes.FailedPrecondition,
+	Validation:        codes.InvalidArgument,
+	Conflict:          codes.Aborted,
+	Precondition:      codes.Aborted,
+	Timeout:           codes.DeadlineExceeded,
+	Canceled:          codes.Canceled,
+	Internal:          codes.Internal,
+}
+
+// GRPCCode maps a Kind to its gRPC status code.
+func GRPCCode(kind Kind) codes.Code {
+	if code, ok := grpcCode[kind]; ok {
+		return code
+	}
+	return codes.Internal
+}
+
+// GRPCStatus converts err to a gRPC status error. The stable code travels as
+// ErrorInfo.Reason and field errors as a BadRequest detail.
+func GRPCStatus(err error) error {
+	e := From(err)
+	st := status.New(GRPCCode(e.Kind), Detail(err))
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: e.Code, Domain: errorDomain}}
+	if len(e.Fields) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(e.Fields))
+		for _, f := range e.Fields {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+	if withDetails, derr := st.WithDetails(details...); derr == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
@@ -0,0 +1,85 @@
+package apperr
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+const ProblemContentType = "application/problem+json"
+
+var httpStatus = map[Kind]int{
+	NotFound:          http.StatusNotFound,
+	AlreadyExists:     http.StatusConflict,
+	InvalidTransition: http.StatusConflict,
+	Validation:        http.StatusBadRequest,
+	Conflict:          http.StatusConflict,
+	Precondition:      http.StatusPreconditionFailed,
+	Timeout:           http.StatusGatewayTimeout,
+	Canceled:          499,
+	Internal:          http.StatusInternalServerError,
+}
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// HTTPStatus maps a Kind to its HTTP status code.
+func HTTPStatus(kind Kind) int {
+	if status, ok := httpStatus[kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// NewProblem describes err for the request path instance.
+func NewProblem(err error, instance string) Problem {
+	e := From(err)
+	p := newProblem(HTTPStatus(e.Kind), e.Code, Detail(err), instance)
+	p.Errors = e.Fields
+	return p
+}
+
+func newProblem(status int, code, detail, instance string) Problem {
+	title := http.StatusText(status)
+	if title == "" {
+		title = "Client Closed Request"
+	}
+	return Problem{
+		Type:     "about:blank",
+		Title:    title,
+		Status:   status,
+		Detail:   detail,
+		Instance: instance,
+		Code:     code,
+	}
+}
+
+// WriteProblem writes err as application/problem+json. Internal errors are
+// logged, since their detail is not sent to the client.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	if KindOf(err) == Internal {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	writeProblem(w, NewProblem(err, r.URL.Path))
+}
+
+// WriteStatus writes a problem for protocol-level failures that are not
+// domain errors, such as an unsupported method or media type.
+func WriteStatus(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblem(w, newProblem(status, code, detail, r.URL.Path))
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -9,10 +9,24 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"homework/internal/metrics"
+)
+
+var (
+	auditQueueDepth = metrics.NewGauge("audit_queue_depth",
+		"Audit records waiting in a processor's channel.", "processor")
+	auditBatchSize = metrics.NewHistogram("audit_batch_size",
+		"Number of audit records flushed per batch.", []float64{1, 5, 10, 25, 50, 100, 250, 500}, "processor")
+	auditProcessorErrors = metrics.NewCounter("audit_processor_errors_total",
+		"Batches a processor failed to write.", "processor")
+	auditDropped = metrics.NewCounter("audit_records_dropped_total",
+		"Audit records dropped because a processor's queue was full.", "processor")
 )
 
 type AuditLog struct {
 	Timestamp time.Time
+	Principal string
 	OrderID   string
 	OldState  string
 	NewState  string
@@ -66,13 +80,16 @@ func (p *StdoutProcessor) Process(batch []AuditLog) error {
 		if p.Filter != "" && !strings.Contains(strings.ToLower(rec.Message), strings.ToLower(p.Filter)) {
 			continue
 		}
-		fmt.Printf("STDOUT: %s | Order: %s | %s -> %s | Msg: %s\n",
-			rec.Timestamp.Format(time.RFC3339), rec.OrderID, rec.OldState, rec.NewState, rec.Message)
+		fmt.Printf("STDOUT: %s | %s | Order: %s | %s -> %s | Msg: %s\n",
+			rec.Timestamp.Format(time.RFC3339), rec.Principal, rec.OrderID, rec.OldState, rec.NewState, rec.Message)
 	}
 	return nil
 }
 
 type ProcessorConfig struct {
+	// Name labels the processor's metrics. It defaults to the processor's
+	// type; a name already taken in the pool gets its position appended.
+	Name        string
 	Processor   AuditLogProcessor
 	BatchSize   int
 	Timeout     time.Duration
@@ -80,6 +97,7 @@ type ProcessorConfig struct {
 }
 
 type auditWorker struct {
+	name      string
 	ch        chan AuditLog
 	processor AuditLogProcessor
 	batchSize int
@@ -91,80 +109,133 @@ func (w *auditWorker) start(ctx context.Context) {
 	w.wg.Add(1)
 	go func() {
 		defer w.wg.Done()
-		batch := make([]AuditLog, 0, w.batchSize)
-		timer := time.NewTimer(w.timeout)
-		defer timer.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				if len(batch) > 0 {
-					if err := w.processor.Process(batch); err != nil {
-						log.Printf("Error processing batch: %v", err)
-					}
-				}
-				return
-			case rec := <-w.ch:
-				batch = append(batch, rec)
-				if len(batch) >= w.batchSize {
-					if !timer.Stop() {
-						<-timer.C
-					}
-					if err := w.processor.Process(batch); err != nil {
-						log.Printf("Error processing batch: %v", err)
-					}
-					batch = nil
-					timer.Reset(w.timeout)
-				}
-			case <-timer.C:
-				if len(batch) > 0 {
-					if err := w.processor.Process(batch); err != nil {
-						log.Printf("Error processing batch: %v", err)
-					}
-					batch = batch[:]
+		w.run(ctx)
+	}()
+}
+
+func (w *auditWorker) run(ctx context.Context) {
+	batch := make([]AuditLog, 0, w.batchSize)
+	timer := time.NewTimer(w.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.drain(batch)
+			return
+		case rec := <-w.ch:
+			batch = append(batch, rec)
+			if len(batch) >= w.batchSize {
+				if !timer.Stop() {
+					<-timer.C
 				}
+				w.flush(batch)
+				batch = nil
 				timer.Reset(w.timeout)
 			}
+		case <-timer.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = nil
+			}
+			timer.Reset(w.timeout)
 		}
-	}()
+	}
+}
+
+// drain flushes the pending batch together with whatever is still queued in
+// the channel, so records logged before shutdown are not lost.
+func (w *auditWorker) drain(batch []AuditLog) {
+	for {
+		select {
+		case rec := <-w.ch:
+			batch = append(batch, rec)
+		default:
+			if len(batch) > 0 {
+				w.flush(batch)
+			}
+			return
+		}
+	}
+}
+
+func (w *auditWorker) flush(batch []AuditLog) {
+	auditBatchSize.Observe(float64(len(batch)), w.name)
+	if err := w.processor.Process(batch); err != nil {
+		auditProcessorErrors.Inc(w.name)
+		log.Printf("Error processing batch: %v", err)
+	}
 }
 
 type AuditWorkerPool struct {
 	workers []*auditWorker
+	cancel  context.CancelFunc
 }
 
 func NewAuditWorkerPool(configs ...ProcessorConfig) *AuditWorkerPool {
 	var workers []*auditWorker
-	for _, cfg := range configs {
+	taken := make(map[string]bool, len(configs))
+	for i, cfg := range configs {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("%T", cfg.Processor)
+		}
+		if taken[name] {
+			name = fmt.Sprintf("%s#%d", name, i)
+		}
+		taken[name] = true
 		worker := &auditWorker{
+			name:      name,
 			ch:        make(chan AuditLog, cfg.ChannelSize),
 			processor: cfg.Processor,
 			batchSize: cfg.BatchSize,
 			timeout:   cfg.Timeout,
 		}
+		auditQueueDepth.SetFunc(func() float64 { return float64(len(worker.ch)) }, worker.name)
 		workers = append(workers, worker)
 	}
 	return &AuditWorkerPool{
 		workers: workers,
+		cancel:  func() {},
 	}
 }
 
+// Start launches the workers. They run until ctx is cancelled or Shutdown is
+// called, whichever comes first.
 func (p *AuditWorkerPool) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
 	for _, worker := range p.workers {
 		worker.start(ctx)
 	}
 }
 
+// Log enqueues the record for every processor without blocking the caller.
+// A processor whose queue is full drops the record.
 func (p *AuditWorkerPool) Log(record AuditLog) {
-	go func(workers []*auditWorker) {
-		for _, worker := range p.workers {
-			worker.ch <- record
+	for _, worker := range p.workers {
+		select {
+		case worker.ch <- record:
+		default:
+			auditDropped.Inc(worker.name)
+			log.Printf("Audit queue of %s is full, dropping record for order %s", worker.name, record.OrderID)
 		}
-	}(p.workers)
-
+	}
 }
 
-func (p *AuditWorkerPool) Shutdown() {
-	for _, worker := range p.workers {
-		worker.wg.Wait()
+// Shutdown stops the workers and waits for them to flush their pending
+// batches, giving up when ctx is done.
+func (p *AuditWorkerPool) Shutdown(ctx context.Context) error {
+	p.cancel()
+	done := make(chan struct{})
+	go func() {
+		for _, worker := range p.workers {
+			worker.wg.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("audit pool shutdown: %w", ctx.Err())
 	}
 }
@@ -0,0 +1,91 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/audit"
+	"homework/internal/metrics"
+)
+
+type recordingProcessor struct {
+	mu      sync.Mutex
+	records []audit.AuditLog
+}
+
+func (p *recordingProcessor) Process(batch []audit.AuditLog) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, batch...)
+	return nil
+}
+
+func (p *recordingProcessor) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.records)
+}
+
+func TestShutdownFlushesPendingRecords(t *testing.T) {
+	proc := &recordingProcessor{}
+	pool := audit.NewAuditWorkerPool(audit.ProcessorConfig{
+		Processor:   proc,
+		BatchSize:   100,
+		Timeout:     time.Hour,
+		ChannelSize: 10,
+	})
+	pool.Start(context.Background())
+
+	for i := 0; i < 3; i++ {
+		pool.Log(audit.AuditLog{OrderID: "ord"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, pool.Shutdown(ctx))
+	assert.Equal(t, 3, proc.count())
+}
+
+func TestLogDoesNotBlockOnFullQueue(t *testing.T) {
+	proc := &recordingProcessor{}
+	pool := audit.NewAuditWorkerPool(audit.ProcessorConfig{
+		Processor:   proc,
+		BatchSize:   100,
+		Timeout:     time.Hour,
+		ChannelSize: 2,
+	})
+
+	// Workers are not started, so nothing drains the queue.
+	for i := 0; i < 5; i++ {
+		pool.Log(audit.AuditLog{OrderID: "ord"})
+	}
+
+	pool.Start(context.Background())
+	require.NoError(t, pool.Shutdown(context.Background()))
+	assert.Equal(t, 2, proc.count())
+}
+
+func TestProcessorsOfOneTypeGetTheirOwnMetrics(t *testing.T) {
+	config := func(size int) audit.ProcessorConfig {
+		return audit.ProcessorConfig{Name: "twin", Processor: &recordingProcessor{}, BatchSize: 100, Timeout: time.Hour, ChannelSize: size}
+	}
+	pool := audit.NewAuditWorkerPool(config(2), config(1))
+
+	// Workers are not started, so the queues keep what is logged.
+	pool.Log(audit.AuditLog{OrderID: "ord"})
+	pool.Log(audit.AuditLog{OrderID: "ord"})
+
+	var out bytes.Buffer
+	_, err := metrics.DefaultRegistry.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `audit_queue_depth{processor="twin"} 2`)
+	assert.Contains(t, out.String(), `audit_queue_depth{processor="twin#1"} 1`)
+	assert.Contains(t, out.String(), `audit_records_dropped_total{processor="twin#1"} 1`)
+	assert.NotContains(t, out.String(), `audit_records_dropped_total{processor="twin"}`)
+}
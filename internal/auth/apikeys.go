@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"homework/internal/apperr"
+)
+
+var (
+	ErrAPIKeyNotFound = apperr.New(apperr.NotFound, "api_key_not_found", "api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrInvalidScope   = apperr.New(apperr.Validation, "invalid_scope", "invalid scope")
+)
+
+const (
+	apiKeyMarker = "pk"
+	// lastUsedResolution limits how often a busy key's last_used_at is written.
+	lastUsedResolution = time.Minute
+)
+
+// APIKey is a long-lived credential of a machine client. Only the SHA-256
+// hash of the secret is stored; Prefix identifies the key in lookups and logs.
+type APIKey struct {
+	ID         int64        `json:"id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	Hash       string       `json:"-"`
+	Scopes     []Permission `json:"scopes"`
+	CreatedBy  string       `json:"created_by"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  time.Time    `json:"expires_at,omitempty"`
+	LastUsedAt time.Time    `json:"last_used_at,omitempty"`
+	RevokedAt  time.Time    `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key may be used at now.
+func (k *APIKey) Active(now time.Time) bool {
+	if !k.RevokedAt.IsZero() {
+		return false
+	}
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+// APIKeyStore persists API keys.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, k *APIKey) error
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*APIKey, error)
+	RotateAPIKey(ctx context.Context, id int64, prefix, hash string) (*APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64, at time.Time) error
+	TouchAPIKey(ctx context.Context, id int64, at time.Time) error
+}
+
+// APIKeys mints, rotates and checks API keys.
+type APIKeys struct {
+	store APIKeyStore
+}
+
+func NewAPIKeys(store APIKeyStore) *APIKeys {
+	return &APIKeys{store: store}
+}
+
+// Mint creates a key and returns it together with the plaintext secret,
+// which is not stored and cannot be shown again.
+func (a *APIKeys) Mint(ctx context.Context, name string, scopes []Permission, expiresAt time.Time, createdBy string) (*APIKey, string, error) {
+	if err := validateScopes(scopes); err != nil {
+		return nil, "", err
+	}
+	prefix, secret, err := newAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+	k := &APIKey{
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hashAPIKey(secret),
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	if err := a.store.CreateAPIKey(ctx, k); err != nil {
+		return nil, "", err
+	}
+	return k, secret, nil
+}
+
+// Rotate replaces the secret of the key, keeping its name, scopes and
+// expiry. The old secret stops working immediately.
+func (a *APIKeys) Rotate(ctx context.Context, id int64) (*APIKey, string, error) {
+	prefix, secret, err := newAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+	k, err := a.store.RotateAPIKey(ctx, id, prefix, hashAPIKey(secret))
+	if err != nil {
+		return nil, "", err
+	}
+	return k, secret, nil
+}
+
+func (a *APIKeys) Revoke(ctx context.Context, id int64) error {
+	return a.store.RevokeAPIKey(ctx, id, time.Now().UTC())
+}
+
+func (a *APIKeys) List(ctx context.Context) ([]*APIKey, error) {
+	return a.store.ListAPIKeys(ctx)
+}
+
+// Authenticate resolves a presented secret into a principal holding the
+// key's scopes.
+func (a *APIKeys) Authenticate(ctx context.Context, secret string) (Principal, error) {
+	prefix, ok := apiKeyPrefix(secret)
+	if !ok {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	k, err := a.store.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashAPIKey(secret))) != 1 {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	now := time.Now().UTC()
+	if !k.Active(now) {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	if now.Sub(k.LastUsedAt) >= lastUsedResolution {
+		if err := a.store.TouchAPIKey(ctx, k.ID, now); err != nil {
+			log.Printf("Error updating last use of api key %s: %v", k.Prefix, err)
+		}
+	}
+	return Principal{Name: fmt.Sprintf("apikey:%d", k.ID), Scopes: k.Scopes}, nil
+}
+
+func validateScopes(scopes []Permission) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	for _, s := range scopes {
+		if !ValidScope(s) {
+			return fmt.Errorf("%w: %q", ErrInvalidScope, s)
+		}
+	}
+	return nil
+}
+
+// newAPIKeySecret returns a secret of the form pk_<prefix>_<random>.
+func newAPIKeySecret() (string, string, error) {
+	buf := make([]byte, 38)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("generate api key: %w", err)
+	}
+	prefix := hex.EncodeToString(buf[:6])
+	return prefix, apiKeyMarker + "_" + prefix + "_" + hex.EncodeToString(buf[6:]), nil
+}
+
+func apiKeyPrefix(secret string) (string, bool) {
+	parts := strings.Split(secret, "_")
+	if len(parts) != 3 || parts[0] != apiKeyMarker || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
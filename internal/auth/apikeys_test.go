@@ -0,0 +1,58 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/auth"
+	"homework/internal/repository/memory"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	ctx := context.Background()
+	keys := auth.NewAPIKeys(memory.NewAPIKeyRepository())
+
+	key, secret, err := keys.Mint(ctx, "partner", []auth.Permission{auth.PermCreate}, time.Time{}, "admin")
+	require.NoError(t, err)
+
+	p, err := keys.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	assert.True(t, p.Can(auth.PermCreate))
+	assert.False(t, p.Can(auth.PermDelete))
+
+	listed, err := keys.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.False(t, listed[0].LastUsedAt.IsZero())
+
+	_, rotated, err := keys.Rotate(ctx, key.ID)
+	require.NoError(t, err)
+	_, err = keys.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+	_, err = keys.Authenticate(ctx, rotated)
+	require.NoError(t, err)
+
+	require.NoError(t, keys.Revoke(ctx, key.ID))
+	_, err = keys.Authenticate(ctx, rotated)
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+}
+
+func TestAPIKeyRejected(t *testing.T) {
+	ctx := context.Background()
+	keys := auth.NewAPIKeys(memory.NewAPIKeyRepository())
+
+	_, _, err := keys.Mint(ctx, "bad", []auth.Permission{auth.PermManageKeys}, time.Time{}, "admin")
+	assert.ErrorIs(t, err, auth.ErrInvalidScope)
+
+	_, expired, err := keys.Mint(ctx, "old", []auth.Permission{auth.PermRead}, time.Now().Add(-time.Minute), "admin")
+	require.NoError(t, err)
+	_, err = keys.Authenticate(ctx, expired)
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+
+	_, err = keys.Authenticate(ctx, "garbage")
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+}
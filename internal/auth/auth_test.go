@@ -0,0 +1,78 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/auth"
+)
+
+func TestPermissionMatrix(t *testing.T) {
+	tests := []struct {
+		role  auth.Role
+		perm  auth.Permission
+		allow bool
+	}{
+		{auth.RoleCourier, auth.PermAccept, true},
+		{auth.RoleCourier, auth.PermCourierReturn, true},
+		{auth.RoleCourier, auth.PermDeliver, false},
+		{auth.RoleClerk, auth.PermDeliver, true},
+		{auth.RoleClerk, auth.PermClientReturn, true},
+		{auth.RoleClerk, auth.PermCreate, false},
+		{auth.RoleAnalyst, auth.PermRead, true},
+		{auth.RoleAnalyst, auth.PermUpdate, false},
+		{auth.RoleAdmin, auth.PermDelete, true},
+		{auth.RoleClerk, auth.PermReadRecipients, true},
+		{auth.RoleClerk, auth.PermManageRecipients, false},
+		{auth.RoleAdmin, auth.PermManageRecipients, true},
+		{auth.Role("guest"), auth.PermRead, false},
+	}
+	for _, tt := range tests {
+		p := auth.Principal{Name: "u", Role: tt.role}
+		assert.Equal(t, tt.allow, p.Can(tt.perm), "%s %s", tt.role, tt.perm)
+	}
+}
+
+func TestLoadUserStore(t *testing.T) {
+	users, err := auth.LoadUserStore("../../config/users.example.json")
+	require.NoError(t, err)
+
+	p, err := users.Authenticate("clerk", "clerk")
+	require.NoError(t, err)
+	assert.Equal(t, auth.Principal{Name: "clerk", Role: auth.RoleClerk, PickupPointID: "default"}, p)
+
+	_, err = users.Authenticate("clerk", "wrong")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = users.Authenticate("nobody", "clerk")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestAuthenticateUnknownUserTakesAsLong(t *testing.T) {
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+	users, err := auth.NewUserStore(auth.User{Name: "admin", Role: auth.RoleAdmin, PasswordHash: hash})
+	require.NoError(t, err)
+	timed := func(name string) time.Duration {
+		start := time.Now()
+		_, err := users.Authenticate(name, "guess")
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+		return time.Since(start)
+	}
+	timed("nobody") // the dummy hash is generated on first use
+
+	known, unknown := timed("admin"), timed("nobody")
+	assert.Greater(t, unknown, known/4, "an unknown user must cost a bcrypt comparison")
+}
+
+func TestNewUserStoreRejectsUnknownRole(t *testing.T) {
+	_, err := auth.NewUserStore(auth.User{Name: "x", Role: "root"})
+	assert.Error(t, err)
+}
+
+func TestNewUserStoreRequiresPickupPointForClerk(t *testing.T) {
+	_, err := auth.NewUserStore(auth.User{Name: "clerk", Role: auth.RoleClerk, PasswordHash: "x"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,9 @@
+package auth
+
+// Authenticator bundles the credential stores accepted by the HTTP and gRPC
+// servers.
+type Authenticator struct {
+	Users   *UserStore
+	Tokens  *TokenIssuer
+	APIKeys *APIKeys
+}
@@ -0,0 +1,16 @@
+package auth
+
+import "context"
+
+type actorKey struct{}
+
+// WithActor stores the name of whoever performs the request in ctx.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by WithActor, or "" if none.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+type Role string
+
+const (
+	RoleCourier Role = "courier"
+	RoleClerk   Role = "clerk"
+	RoleAdmin   Role = "admin"
+	RoleAnalyst Role = "analyst"
+)
+
+type Permission string
+
+const (
+	PermRead          Permission = "orders:read"
+	PermCreate        Permission = "orders:create"
+	PermUpdate        Permission = "orders:update"
+	PermDelete        Permission = "orders:delete"
+	PermAccept        Permission = "orders:accept"
+	PermDeliver       Permission = "orders:deliver"
+	PermClientReturn  Permission = "orders:client_return"
+	PermCourierReturn Permission = "orders:courier_return"
+	PermManageKeys    Permission = "apikeys:manage"
+
+	PermReadRecipients   Permission = "recipients:read"
+	PermManageRecipients Permission = "recipients:manage"
+
+	PermManagePickupPoints Permission = "pickup_points:manage"
+)
+
+// orderPermissions are the permissions that may also be granted to API keys
+// as scopes.
+var orderPermissions = []Permission{
+	PermRead, PermCreate, PermUpdate, PermDelete,
+	PermAccept, PermDeliver, PermClientReturn, PermCourierReturn,
+}
+
+// recipientPermissions guard the contact details of recipients. They may
+// also be granted to API keys.
+var recipientPermissions = []Permission{PermReadRecipients, PermManageRecipients}
+
+// rolePermissions is the permission matrix. Every role may read orders;
+// clerks, who hand parcels over, may also look recipients up; admin may do
+// everything.
+var rolePermissions = map[Role][]Permission{
+	RoleCourier: {PermRead, PermAccept, PermCourierReturn},
+	RoleClerk:   {PermRead, PermDeliver, PermClientReturn, PermReadRecipients},
+	RoleAnalyst: {PermRead},
+	RoleAdmin:   slices.Concat([]Permission{PermManageKeys, PermManagePickupPoints}, orderPermissions, recipientPermissions),
+}
+
+// Scoped reports whether users with the role work at a single pickup point
+// and only see its orders.
+func (r Role) Scoped() bool {
+	return r == RoleClerk
+}
+
+// Valid reports whether r is one of the known roles.
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Principal is an authenticated caller: a user with a role or an API key
+// with scopes. A non-empty PickupPointID restricts the caller to the orders
+// of that point.
+type Principal struct {
+	Name          string
+	Role          Role
+	Scopes        []Permission
+	PickupPointID string
+}
+
+// Can reports whether the principal's role or scopes grant perm.
+func (p Principal) Can(perm Permission) bool {
+	return containsPermission(rolePermissions[p.Role], perm) || containsPermission(p.Scopes, perm)
+}
+
+// ValidScope reports whether perm may be granted to an API key.
+func ValidScope(perm Permission) bool {
+	return containsPermission(orderPermissions, perm) || containsPermission(recipientPermissions, perm)
+}
+
+func containsPermission(perms []Permission, perm Permission) bool {
+	for _, granted := range perms {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// MethodPermissions maps a request method (an HTTP verb or a full gRPC
+// method name) to the permission it requires. Methods missing from the map
+// are not allowed at all.
+type MethodPermissions map[string]Permission
+
+type principalKey struct{}
+
+// WithPrincipal stores p in ctx and records its name as the actor.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	ctx = context.WithValue(ctx, principalKey{}, p)
+	return WithActor(ctx, p.Name)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// PickupPointScope returns the pickup point the caller in ctx is restricted
+// to, or "" if it may see every point.
+func PickupPointScope(ctx context.Context) string {
+	p, _ := PrincipalFromContext(ctx)
+	return p.PickupPointID
+}
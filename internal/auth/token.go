@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// minKeySize is the shortest HMAC key accepted for HS256.
+const minKeySize = 32
+
+// Denylist stores ids of tokens revoked before they expire.
+type Denylist interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// Claims is the payload of the bearer tokens issued by TokenIssuer.
+type Claims struct {
+	Role          Role   `json:"role"`
+	PickupPointID string `json:"pickup_point_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenIssuer signs and verifies HS256 bearer tokens with a local key.
+type TokenIssuer struct {
+	key      []byte
+	issuer   string
+	ttl      time.Duration
+	denylist Denylist
+}
+
+func NewTokenIssuer(key []byte, issuer string, ttl time.Duration, denylist Denylist) (*TokenIssuer, error) {
+	if len(key) < minKeySize {
+		return nil, fmt.Errorf("signing key must be at least %d bytes", minKeySize)
+	}
+	return &TokenIssuer{key: key, issuer: issuer, ttl: ttl, denylist: denylist}, nil
+}
+
+// LoadSigningKey reads the HMAC key from path, ignoring surrounding whitespace.
+func LoadSigningKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+	return bytes.TrimSpace(data), nil
+}
+
+// RandomSigningKey returns a fresh key for setups without a key file. Tokens
+// signed with it do not survive a restart.
+func RandomSigningKey() ([]byte, error) {
+	key := make([]byte, minKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Issue signs a token for p that expires after the configured TTL.
+func (t *TokenIssuer) Issue(p Principal) (string, time.Time, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", time.Time{}, fmt.Errorf("issue token: %w", err)
+	}
+	now := time.Now()
+	expiresAt := now.Add(t.ttl)
+	claims := Claims{
+		Role:          p.Role,
+		PickupPointID: p.PickupPointID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
+			Issuer:    t.issuer,
+			Subject:   p.Name,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.key)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("issue token: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// Verify checks the signature, issuer, expiry and role of the token and that
+// it has not been revoked.
+func (t *TokenIssuer) Verify(ctx context.Context, token string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return t.key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(t.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" || claims.ID == "" || !claims.Role.Valid() {
+		return nil, fmt.Errorf("%w: missing subject, id or role", ErrInvalidToken)
+	}
+	if claims.Role.Scoped() && claims.PickupPointID == "" {
+		return nil, fmt.Errorf("%w: missing pickup point", ErrInvalidToken)
+	}
+	revoked, err := t.denylist.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, fmt.Errorf("%w: revoked", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// Revoke puts the token into the denylist until it expires.
+func (t *TokenIssuer) Revoke(ctx context.Context, claims *Claims) error {
+	return t.denylist.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+// Principal returns the caller the token was issued to.
+func (c *Claims) Principal() Principal {
+	return Principal{Name: c.Subject, Role: c.Role, PickupPointID: c.PickupPointID}
+}
+
+type claimsKey struct{}
+
+// WithClaims stores the verified bearer token claims in ctx together with
+// the principal they identify.
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	ctx = context.WithValue(ctx, claimsKey{}, c)
+	return WithPrincipal(ctx, c.Principal())
+}
+
+// ClaimsFromContext returns the claims stored by WithClaims.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*Claims)
+	return c, ok
+}
@@ -0,0 +1,62 @@
+package auth_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/auth"
+	"homework/internal/repository/memory"
+)
+
+var testKey = []byte(strings.Repeat("k", 32))
+
+func TestTokenRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	tokens, err := auth.NewTokenIssuer(testKey, "orders", time.Hour, memory.NewRevokedTokenRepository())
+	require.NoError(t, err)
+
+	clerk := auth.Principal{Name: "clerk", Role: auth.RoleClerk, PickupPointID: "pvz1"}
+	token, expiresAt, err := tokens.Issue(clerk)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+	claims, err := tokens.Verify(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, clerk, claims.Principal())
+
+	require.NoError(t, tokens.Revoke(ctx, claims))
+	_, err = tokens.Verify(ctx, token)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestTokenRejected(t *testing.T) {
+	ctx := context.Background()
+	denylist := memory.NewRevokedTokenRepository()
+	tokens, err := auth.NewTokenIssuer(testKey, "orders", time.Hour, denylist)
+	require.NoError(t, err)
+	principal := auth.Principal{Name: "admin", Role: auth.RoleAdmin}
+
+	otherIssuer, err := auth.NewTokenIssuer(testKey, "someone-else", time.Hour, denylist)
+	require.NoError(t, err)
+	otherKey, err := auth.NewTokenIssuer([]byte(strings.Repeat("x", 32)), "orders", time.Hour, denylist)
+	require.NoError(t, err)
+	expired, err := auth.NewTokenIssuer(testKey, "orders", -time.Minute, denylist)
+	require.NoError(t, err)
+
+	for name, issuer := range map[string]*auth.TokenIssuer{
+		"issuer": otherIssuer, "signature": otherKey, "expired": expired,
+	} {
+		token, _, err := issuer.Issue(principal)
+		require.NoError(t, err)
+		_, err = tokens.Verify(ctx, token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken, name)
+	}
+
+	_, err = auth.NewTokenIssuer([]byte("short"), "orders", time.Hour, denylist)
+	assert.Error(t, err)
+}
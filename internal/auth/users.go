@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// dummyHash is compared against for unknown users, so that a login attempt
+// takes as long whether or not the name exists.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("no such user"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(fmt.Sprintf("generate dummy password hash: %v", err))
+	}
+	return hash
+})
+
+// User is an entry of the users file. PasswordHash is a bcrypt hash.
+// PickupPointID ties the user to one pickup point and is required for
+// clerks.
+type User struct {
+	Name          string `json:"name"`
+	Role          Role   `json:"role"`
+	PasswordHash  string `json:"password_hash"`
+	PickupPointID string `json:"pickup_point_id,omitempty"`
+}
+
+type UserStore struct {
+	users map[string]User
+}
+
+func NewUserStore(users ...User) (*UserStore, error) {
+	store := &UserStore{users: make(map[string]User, len(users))}
+	for _, u := range users {
+		if u.Name == "" {
+			return nil, errors.New("user without a name")
+		}
+		if !u.Role.Valid() {
+			return nil, fmt.Errorf("user %q: unknown role %q", u.Name, u.Role)
+		}
+		if u.Role.Scoped() && u.PickupPointID == "" {
+			return nil, fmt.Errorf("user %q: role %q requires a pickup point", u.Name, u.Role)
+		}
+		if _, ok := store.users[u.Name]; ok {
+			return nil, fmt.Errorf("user %q defined twice", u.Name)
+		}
+		store.users[u.Name] = u
+	}
+	return store, nil
+}
+
+// LoadUserStore reads a JSON array of users from path.
+func LoadUserStore(path string) (*UserStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read users file: %w", err)
+	}
+	var users []User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("parse users file: %w", err)
+	}
+	return NewUserStore(users...)
+}
+
+// HashPassword returns the bcrypt hash to put into a users file.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Authenticate checks the password of the named user. An unknown name costs
+// a bcrypt comparison too, so response times do not tell which names exist.
+func (s *UserStore) Authenticate(name, password string) (Principal, error) {
+	u, ok := s.users[name]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return Principal{}, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Name: u.Name, Role: u.Role, PickupPointID: u.PickupPointID}, nil
+}
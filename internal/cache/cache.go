@@ -10,7 +10,7 @@ import (
 )
 
 type Cache interface {
-	Refresh(repo repository.Repository) error
+	Refresh(ctx context.Context, repo repository.Repository) error
 }
 
 type ActiveOrdersCache struct {
@@ -24,6 +24,14 @@ func NewActiveOrdersCache() *ActiveOrdersCache {
 	}
 }
 
+func (c *ActiveOrdersCache) Get(id string) (*models.Order, bool) {
+	c.Mu.RLock()
+	order, ok := c.Orders[id]
+	c.Mu.RUnlock()
+	RecordLookup(ActiveName, ok)
+	return order, ok
+}
+
 type HistoryCache struct {
 	mu     sync.RWMutex
 	orders []*models.Order
@@ -35,8 +43,9 @@ func NewHistoryCache() *HistoryCache {
 	}
 }
 
-func (c *HistoryCache) Refresh(repo repository.Repository) error {
-	orders, err := repo.List("", 1000, "")
+func (c *HistoryCache) Refresh(ctx context.Context, repo repository.Repository) error {
+	defer ObserveRefresh(HistoryName, time.Now())
+	orders, err := repo.List(ctx, "", 1000, "")
 	if err != nil {
 		return err
 	}
@@ -58,7 +67,7 @@ func (c *HistoryCache) StartAutoRefresh(ctx context.Context, repo repository.Rep
 	for {
 		select {
 		case <-ticker.C:
-			if err := c.Refresh(repo); err != nil {
+			if err := c.Refresh(ctx, repo); err != nil {
 				return
 			}
 		case <-ctx.Done():
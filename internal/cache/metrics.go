@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"time"
+
+	"homework/internal/metrics"
+)
+
+const (
+	ActiveName  = "active"
+	HistoryName = "history"
+)
+
+var (
+	cacheHits = metrics.NewCounter("cache_hits_total",
+		"Cache lookups served from memory.", "cache")
+	cacheMisses = metrics.NewCounter("cache_misses_total",
+		"Cache lookups that fell through to the repository.", "cache")
+	cacheRefreshDuration = metrics.NewHistogram("cache_refresh_duration_seconds",
+		"Time spent reloading a cache from the repository.", metrics.DefBuckets, "cache")
+)
+
+// RecordLookup counts a lookup against the named cache as a hit or a miss.
+func RecordLookup(name string, hit bool) {
+	if hit {
+		cacheHits.Inc(name)
+		return
+	}
+	cacheMisses.Inc(name)
+}
+
+// ObserveRefresh records how long a refresh of the named cache took since start.
+func ObserveRefresh(name string, start time.Time) {
+	cacheRefreshDuration.Observe(time.Since(start).Seconds(), name)
+}
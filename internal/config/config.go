@@ -1,22 +1,111 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/IBM/sarama"
+	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	DSN          string
-	HTTPPort     string
-	Username     string
-	Password     string
+	DSN      string
+	HTTPPort string
+	GRPCPort string
+	Username string
+	Password string
+	// UsersFile is a JSON list of users with roles and bcrypt hashes. When
+	// empty, Username/Password become the only user, with the admin role.
+	UsersFile    string
 	FilterWord   string
 	KafkaBrokers []string
 	KafkaGroupID string
 	KafkaTopic   string
 	KafkaConfig  *sarama.Config
+	Timeouts     OperationTimeouts
+	Expiry       ExpiryConfig
+	// ShutdownTimeout bounds the whole graceful shutdown sequence.
+	ShutdownTimeout time.Duration
+	JWT             JWTConfig
+	RateLimits      RateLimitConfig
+	// IdempotencyTTL is how long responses to requests with an
+	// Idempotency-Key are kept for replay.
+	IdempotencyTTL time.Duration
+	// MaxStorageHorizon is how far ahead an order's storage deadline may be.
+	MaxStorageHorizon time.Duration
+	// CursorKeyFile holds the HMAC key of pagination cursors. Without it a
+	// random key is generated and cursors do not survive a restart.
+	CursorKeyFile string
+	// AutoCreateRecipients lets an order for an unknown recipient create a
+	// bare recipient record; otherwise such orders are rejected.
+	AutoCreateRecipients bool
+	// DefaultPickupPoint receives the orders created without a pickup point
+	// by callers that are not bound to one.
+	DefaultPickupPoint string
+}
+
+// RateLimit bounds one route. Rates are requests per second per client,
+// bursts are bucket sizes, MaxInFlight caps concurrent requests to the route
+// across all clients. Zero or negative values disable the respective limit.
+type RateLimit struct {
+	ReadRate    float64 `json:"read_rate"`
+	ReadBurst   int     `json:"read_burst"`
+	WriteRate   float64 `json:"write_rate"`
+	WriteBurst  int     `json:"write_burst"`
+	MaxInFlight int     `json:"max_in_flight"`
+}
+
+// RateLimitConfig holds the limits applied to every route and per-route
+// overrides keyed by the route pattern, e.g. "/orders".
+type RateLimitConfig struct {
+	Default RateLimit
+	Routes  map[string]RateLimit
+	// AuthFailureRate and AuthFailureBurst bound failed authentication per
+	// client IP across all routes; a rate of zero or less disables the limit.
+	AuthFailureRate  float64
+	AuthFailureBurst int
+}
+
+// For returns the limits of route. Fields an override leaves at zero are
+// taken from the default; set them negative to disable a limit on the route.
+func (c RateLimitConfig) For(route string) RateLimit {
+	l := c.Default
+	o, ok := c.Routes[route]
+	if !ok {
+		return l
+	}
+	l.ReadRate = override(l.ReadRate, o.ReadRate)
+	l.ReadBurst = override(l.ReadBurst, o.ReadBurst)
+	l.WriteRate = override(l.WriteRate, o.WriteRate)
+	l.WriteBurst = override(l.WriteBurst, o.WriteBurst)
+	l.MaxInFlight = override(l.MaxInFlight, o.MaxInFlight)
+	return l
+}
+
+// JWTConfig controls the bearer tokens issued by POST /auth/token. Without a
+// key file a random key is generated at startup.
+type JWTConfig struct {
+	KeyFile string
+	Issuer  string
+	TTL     time.Duration
+}
+
+// ExpiryConfig controls the job that returns orders past their storage
+// deadline to the courier.
+type ExpiryConfig struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+// OperationTimeouts bound how long a single service call may spend in the
+// database. Zero disables the limit.
+type OperationTimeouts struct {
+	Read       time.Duration
+	Write      time.Duration
+	Transition time.Duration
 }
 
 func LoadConfig() *Config {
@@ -27,16 +116,57 @@ func LoadConfig() *Config {
 	return &Config{
 		DSN:          getEnv("APP_DSN", "host=localhost user=postgres password=postgres dbname=pickups sslmode=disable"),
 		HTTPPort:     getEnv("APP_PORT", "9000"),
+		GRPCPort:     getEnv("APP_GRPC_PORT", "9001"),
 		Username:     getEnv("APP_USER", "admin"),
 		Password:     getEnv("APP_PASS", "secret"),
+		UsersFile:    getEnv("APP_USERS_FILE", ""),
 		FilterWord:   getEnv("APP_FILTER", ""),
 		KafkaBrokers: strings.Split(brokersStr, ","),
 		KafkaGroupID: getEnv("KAFKA_GROUP_ID", "audit-group"),
 		KafkaTopic:   getEnv("KAFKA_TOPIC", "audit-tasks"),
 		KafkaConfig:  config,
+		Timeouts: OperationTimeouts{
+			Read:       getDuration("APP_READ_TIMEOUT", 3*time.Second),
+			Write:      getDuration("APP_WRITE_TIMEOUT", 5*time.Second),
+			Transition: getDuration("APP_TRANSITION_TIMEOUT", 5*time.Second),
+		},
+		Expiry: ExpiryConfig{
+			Interval:  getDuration("APP_EXPIRY_INTERVAL", time.Minute),
+			BatchSize: getInt("APP_EXPIRY_BATCH", 100),
+		},
+		ShutdownTimeout: getDuration("APP_SHUTDOWN_TIMEOUT", 15*time.Second),
+		JWT: JWTConfig{
+			KeyFile: getEnv("APP_JWT_KEY_FILE", ""),
+			Issuer:  getEnv("APP_JWT_ISSUER", "pickup-orders"),
+			TTL:     getDuration("APP_JWT_TTL", time.Hour),
+		},
+		RateLimits: RateLimitConfig{
+			Default: RateLimit{
+				ReadRate:    getFloat("APP_READ_RATE", 50),
+				ReadBurst:   getInt("APP_READ_BURST", 100),
+				WriteRate:   getFloat("APP_WRITE_RATE", 10),
+				WriteBurst:  getInt("APP_WRITE_BURST", 20),
+				MaxInFlight: getInt("APP_MAX_IN_FLIGHT", 64),
+			},
+			Routes:           getRouteLimits("APP_ROUTE_RATE_LIMITS"),
+			AuthFailureRate:  getFloat("APP_AUTH_FAILURE_RATE", 0.2),
+			AuthFailureBurst: getInt("APP_AUTH_FAILURE_BURST", 10),
+		},
+		IdempotencyTTL:       getDuration("APP_IDEMPOTENCY_TTL", 24*time.Hour),
+		MaxStorageHorizon:    getDuration("APP_MAX_STORAGE_HORIZON", 30*24*time.Hour),
+		CursorKeyFile:        getEnv("APP_CURSOR_KEY_FILE", ""),
+		AutoCreateRecipients: getBool("APP_AUTO_CREATE_RECIPIENTS", true),
+		DefaultPickupPoint:   getEnv("APP_DEFAULT_PICKUP_POINT", "default"),
 	}
 }
 
+func override[T int | float64](def, o T) T {
+	if o != 0 {
+		return o
+	}
+	return def
+}
+
 func getEnv(key, defaultVal string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -44,6 +174,77 @@ func getEnv(key, defaultVal string) string {
 	return defaultVal
 }
 
+func getDuration(key string, defaultVal time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration %s=%q, using %s", key, value, defaultVal)
+		return defaultVal
+	}
+	return d
+}
+
+func getInt(key string, defaultVal int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid number %s=%q, using %d", key, value, defaultVal)
+		return defaultVal
+	}
+	return n
+}
+
+func getBool(key string, defaultVal bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean %s=%q, using %t", key, value, defaultVal)
+		return defaultVal
+	}
+	return b
+}
+
+func getFloat(key string, defaultVal float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid number %s=%q, using %g", key, value, defaultVal)
+		return defaultVal
+	}
+	return f
+}
+
+// getRouteLimits parses a JSON object of per-route overrides, e.g.
+// {"/orders": {"write_rate": 2, "write_burst": 5}}.
+func getRouteLimits(key string) map[string]RateLimit {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return nil
+	}
+	var routes map[string]RateLimit
+	if err := json.Unmarshal([]byte(value), &routes); err != nil {
+		log.Printf("Invalid route limits %s: %v, ignoring", key, err)
+		return nil
+	}
+	return routes
+}
+
 func (c *Config) Addr() string {
 	return fmt.Sprintf(":%s", c.HTTPPort)
 }
+
+func (c *Config) GRPCAddr() string {
+	return fmt.Sprintf(":%s", c.GRPCPort)
+}
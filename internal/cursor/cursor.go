@@ -0,0 +1,63 @@
+// Package cursor turns pagination state into opaque tokens. A token is the
+// base64url JSON of the state followed by its HMAC-SHA256, so clients can
+// pass it back but cannot forge or alter it.
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"homework/internal/apperr"
+)
+
+var ErrInvalid = apperr.New(apperr.Validation, "invalid_cursor", "invalid cursor")
+
+type Codec struct {
+	key []byte
+}
+
+func NewCodec(key []byte) *Codec {
+	return &Codec{key: key}
+}
+
+// Encode signs the JSON encoding of state.
+func (c *Codec) Encode(state any) (string, error) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(c.sign(payload)), nil
+}
+
+// Decode verifies token and unmarshals its state into dst. Any malformed or
+// altered token yields ErrInvalid.
+func (c *Codec) Decode(token string, dst any) error {
+	enc := base64.RawURLEncoding
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalid
+	}
+	payload, err := enc.DecodeString(encodedPayload)
+	if err != nil {
+		return ErrInvalid
+	}
+	mac, err := enc.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, c.sign(payload)) {
+		return ErrInvalid
+	}
+	if err := json.Unmarshal(payload, dst); err != nil {
+		return ErrInvalid
+	}
+	return nil
+}
+
+func (c *Codec) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, c.key)
+	h.Write(payload)
+	return h.Sum(nil)
+}
@@ -0,0 +1,30 @@
+package cursor_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/cursor"
+)
+
+type state struct {
+	ID string `json:"id"`
+}
+
+func TestCodec(t *testing.T) {
+	c := cursor.NewCodec([]byte("0123456789abcdef0123456789abcdef"))
+	token, err := c.Encode(state{ID: "ord1"})
+	require.NoError(t, err)
+
+	var got state
+	require.NoError(t, c.Decode(token, &got))
+	assert.Equal(t, "ord1", got.ID)
+
+	forged, err := cursor.NewCodec([]byte("another key")).Encode(state{ID: "ord9"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, c.Decode(forged, &got), cursor.ErrInvalid)
+	assert.ErrorIs(t, c.Decode("ord1", &got), cursor.ErrInvalid)
+	assert.ErrorIs(t, c.Decode(token[1:], &got), cursor.ErrInvalid)
+}
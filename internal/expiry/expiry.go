@@ -0,0 +1,95 @@
+package expiry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"homework/internal/audit"
+	"homework/internal/auth"
+	"homework/internal/models"
+)
+
+// Actor is recorded in the order history for orders expired by the scheduler.
+const Actor = "system:expiry"
+
+type Expirer interface {
+	ExpireOverdueOrders(ctx context.Context, now time.Time, limit int) ([]*models.Order, error)
+}
+
+// Scheduler periodically returns accepted orders whose storage deadline has
+// passed to the courier.
+type Scheduler struct {
+	expirer   Expirer
+	auditPool *audit.AuditWorkerPool
+	interval  time.Duration
+	batchSize int
+}
+
+func NewScheduler(expirer Expirer, auditPool *audit.AuditWorkerPool, interval time.Duration, batchSize int) *Scheduler {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Scheduler{
+		expirer:   expirer,
+		auditPool: auditPool,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.RunOnce(ctx); err != nil {
+				log.Printf("Error expiring orders: %v", err)
+			} else if n > 0 {
+				log.Printf("Returned %d expired orders to courier", n)
+			}
+		}
+	}
+}
+
+// RunOnce expires overdue orders batch by batch until none are left and
+// returns how many were moved.
+func (s *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	ctx = auth.WithActor(ctx, Actor)
+	total := 0
+	for {
+		orders, err := s.expirer.ExpireOverdueOrders(ctx, time.Now().UTC(), s.batchSize)
+		if err != nil {
+			return total, err
+		}
+		for _, o := range orders {
+			s.logExpired(o)
+		}
+		total += len(orders)
+		if len(orders) < s.batchSize {
+			return total, nil
+		}
+	}
+}
+
+func (s *Scheduler) logExpired(o *models.Order) {
+	oldState, newState := string(models.OrderStateAccepted), string(models.OrderStateReturned)
+	s.auditPool.Log(audit.AuditLog{
+		Timestamp: time.Now().UTC(),
+		Principal: Actor,
+		OrderID:   o.ID,
+		OldState:  oldState,
+		NewState:  newState,
+		Endpoint:  "expiry",
+		Request:   fmt.Sprintf("storage deadline %s", o.StorageDeadline.Format(time.RFC3339)),
+		Response:  fmt.Sprintf("%s -> %s", oldState, newState),
+		Message:   "storage deadline expired",
+	})
+}
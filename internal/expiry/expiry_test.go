@@ -0,0 +1,59 @@
+package expiry_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/audit"
+	"homework/internal/cache"
+	"homework/internal/config"
+	"homework/internal/expiry"
+	"homework/internal/models"
+	"homework/internal/packaging"
+	"homework/internal/repository/memory"
+	"homework/internal/service"
+)
+
+func TestRunOnceExpiresInBatches(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewOrderRepository()
+	svc := service.NewOrderService(repo, cache.NewActiveOrdersCache(), cache.NewHistoryCache(), config.OperationTimeouts{},
+		service.NewValidator(0, packaging.Default),
+		service.NewRecipientService(memory.NewRecipientRepository(), config.OperationTimeouts{}, true),
+		service.NewPickupPointService(repo, config.OperationTimeouts{}, ""))
+
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("ord%d", i)
+		require.NoError(t, repo.Create(ctx, &models.Order{ID: id, StorageDeadline: time.Now().Add(-time.Hour)}))
+		require.NoError(t, repo.AcceptOrder(ctx, id))
+	}
+
+	scheduler := expiry.NewScheduler(svc, audit.NewAuditWorkerPool(), time.Minute, 2)
+	n, err := scheduler.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	for i := 0; i < 5; i++ {
+		o, err := svc.GetOrderByID(ctx, fmt.Sprintf("ord%d", i))
+		require.NoError(t, err)
+		assert.Equal(t, models.OrderStateReturned, o.CurrentState())
+	}
+
+	n, err = scheduler.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestStartWithoutInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		scheduler := expiry.NewScheduler(nil, audit.NewAuditWorkerPool(), interval, 0)
+		assert.NotPanics(t, func() { scheduler.Start(ctx) }, "interval %s", interval)
+	}
+}
@@ -0,0 +1,93 @@
+package grpcserver
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"homework/internal/models"
+	orderpb "homework/internal/pb/order"
+)
+
+func orderToPB(o *models.Order) *orderpb.Order {
+	return &orderpb.Order{
+		Id:              o.ID,
+		RecipientId:     o.RecipientID,
+		StorageDeadline: timeToPB(o.StorageDeadline),
+		AcceptedAt:      timeToPB(o.AcceptedAt),
+		DeliveredAt:     timeToPB(o.DeliveredAt),
+		ReturnedAt:      timeToPB(o.ReturnedAt),
+		ClientReturnAt:  timeToPB(o.ClientReturnAt),
+		LastStateChange: timeToPB(o.LastStateChange),
+		Weight:          o.Weight,
+		Cost:            o.Cost,
+		FinalCost:       o.FinalCost,
+		Packaging:       o.Packaging,
+		State:           string(o.CurrentState()),
+		Version:         o.Version,
+		PickupPointId:   o.PickupPointID,
+	}
+}
+
+func ordersToPB(orders []*models.Order) *orderpb.ListOrdersResponse {
+	resp := &orderpb.ListOrdersResponse{Orders: make([]*orderpb.Order, 0, len(orders))}
+	for _, o := range orders {
+		resp.Orders = append(resp.Orders, orderToPB(o))
+	}
+	return resp
+}
+
+func eventsToPB(events []models.OrderEvent) *orderpb.GetOrderHistoryResponse {
+	resp := &orderpb.GetOrderHistoryResponse{Events: make([]*orderpb.OrderEvent, 0, len(events))}
+	for _, e := range events {
+		pe := &orderpb.OrderEvent{
+			Id:        e.ID,
+			OrderId:   e.OrderID,
+			Type:      string(e.Type),
+			OldState:  string(e.OldState),
+			NewState:  string(e.NewState),
+			Actor:     e.Actor,
+			CreatedAt: timeToPB(e.CreatedAt),
+		}
+		for _, c := range e.Changes {
+			pe.Changes = append(pe.Changes, &orderpb.FieldChange{
+				Field:   c.Field,
+				OldJson: string(c.Old),
+				NewJson: string(c.New),
+			})
+		}
+		resp.Events = append(resp.Events, pe)
+	}
+	return resp
+}
+
+func orderFromPB(o *orderpb.Order) *models.Order {
+	return &models.Order{
+		ID:              o.GetId(),
+		RecipientID:     o.GetRecipientId(),
+		StorageDeadline: timeFromPB(o.GetStorageDeadline()),
+		AcceptedAt:      timeFromPB(o.GetAcceptedAt()),
+		DeliveredAt:     timeFromPB(o.GetDeliveredAt()),
+		ReturnedAt:      timeFromPB(o.GetReturnedAt()),
+		ClientReturnAt:  timeFromPB(o.GetClientReturnAt()),
+		Weight:          o.GetWeight(),
+		Cost:            o.GetCost(),
+		Packaging:       o.GetPackaging(),
+		Version:         o.GetVersion(),
+		PickupPointID:   o.GetPickupPointId(),
+	}
+}
+
+func timeToPB(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timeFromPB(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
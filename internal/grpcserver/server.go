@@ -0,0 +1,247 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/audit"
+	"homework/internal/auth"
+	"homework/internal/config"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"homework/internal/apperr"
+	"homework/internal/cursor"
+	"homework/internal/middleware"
+	"homework/internal/models"
+	orderpb "homework/internal/pb/order"
+	"homework/internal/repository"
+	"homework/internal/wrapper"
+)
+
+// methodPermissions is the gRPC side of the permission matrix used by the
+// HTTP routes.
+var methodPermissions = auth.MethodPermissions{
+	orderpb.OrderService_CreateOrder_FullMethodName:        auth.PermCreate,
+	orderpb.OrderService_GetOrder_FullMethodName:           auth.PermRead,
+	orderpb.OrderService_UpdateOrder_FullMethodName:        auth.PermUpdate,
+	orderpb.OrderService_DeleteOrder_FullMethodName:        auth.PermDelete,
+	orderpb.OrderService_AcceptOrder_FullMethodName:        auth.PermAccept,
+	orderpb.OrderService_DeliverOrder_FullMethodName:       auth.PermDeliver,
+	orderpb.OrderService_ClientReturnOrder_FullMethodName:  auth.PermClientReturn,
+	orderpb.OrderService_CourierReturnOrder_FullMethodName: auth.PermCourierReturn,
+	orderpb.OrderService_ListActiveOrders_FullMethodName:   auth.PermRead,
+	orderpb.OrderService_ListHistoryOrders_FullMethodName:  auth.PermRead,
+	orderpb.OrderService_ListReturns_FullMethodName:        auth.PermRead,
+	orderpb.OrderService_GetOrderHistory_FullMethodName:    auth.PermRead,
+}
+
+type Server struct {
+	orderpb.UnimplementedOrderServiceServer
+
+	wrap      *wrapper.OrderWrapper
+	authn     *auth.Authenticator
+	cursors   *cursor.Codec
+	addr      string
+	auditPool *audit.AuditWorkerPool
+	grpc      *grpc.Server
+}
+
+func NewServer(wrap *wrapper.OrderWrapper, cfg *config.Config, auditPool *audit.AuditWorkerPool, authn *auth.Authenticator, cursors *cursor.Codec) *Server {
+	s := &Server{
+		wrap:      wrap,
+		authn:     authn,
+		cursors:   cursors,
+		addr:      cfg.GRPCAddr(),
+		auditPool: auditPool,
+	}
+	s.grpc = grpc.NewServer(grpc.ChainUnaryInterceptor(
+		middleware.AuthenticateUnaryInterceptor(s.authn),
+		middleware.AuditUnaryInterceptor(s.auditPool),
+		middleware.AuthorizeUnaryInterceptor(methodPermissions),
+	))
+	s.Register(s.grpc)
+	return s
+}
+
+func (s *Server) Register(gs *grpc.Server) {
+	orderpb.RegisterOrderServiceServer(gs, s)
+}
+
+func (s *Server) Run() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("grpc listen: %w", err)
+	}
+	log.Printf("gRPC server listen on %s...", s.addr)
+	return s.grpc.Serve(lis)
+}
+
+// Shutdown waits for in-flight RPCs to finish and stops the server. If ctx
+// is done first, the remaining RPCs are cancelled.
+func (s *Server) Shutdown(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.grpc.Stop()
+	}
+}
+
+func (s *Server) logStatusTransition(ctx context.Context, orderID, oldState, newState, method string) {
+	s.auditPool.Log(audit.AuditLog{
+		Timestamp: time.Now().UTC(),
+		Principal: auth.ActorFromContext(ctx),
+		OrderID:   orderID,
+		OldState:  oldState,
+		NewState:  newState,
+		Endpoint:  method,
+		Request:   "status transition",
+		Response:  fmt.Sprintf("%s -> %s", oldState, newState),
+		Message:   "status transition succeeded",
+	})
+}
+
+func (s *Server) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.Order, error) {
+	o := orderFromPB(req.GetOrder())
+	o.LastStateChange = time.Now().UTC()
+	if err := s.wrap.CreateOrder(ctx, o); err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	s.logStatusTransition(ctx, o.ID, "", string(models.OrderStateAccepted), orderpb.OrderService_CreateOrder_FullMethodName)
+	return orderToPB(o), nil
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	o, err := s.wrap.GetOrderByID(ctx, req.GetId())
+	if err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	return orderToPB(o), nil
+}
+
+func (s *Server) UpdateOrder(ctx context.Context, req *orderpb.UpdateOrderRequest) (*orderpb.Order, error) {
+	updated := orderFromPB(req.GetOrder())
+	version := updated.Version
+	if version <= 0 {
+		return nil, status.Error(codes.FailedPrecondition, "order.version is required")
+	}
+	old, err := s.wrap.GetOrderByID(ctx, updated.ID)
+	if err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	oldState := string(old.CurrentState())
+
+	updated.LastStateChange = time.Now().UTC()
+	if err := s.wrap.UpdateOrderIfVersion(ctx, updated, version); err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	if newState := string(updated.CurrentState()); oldState != newState {
+		s.logStatusTransition(ctx, updated.ID, oldState, newState, orderpb.OrderService_UpdateOrder_FullMethodName)
+	}
+	return orderToPB(updated), nil
+}
+
+func (s *Server) DeleteOrder(ctx context.Context, req *orderpb.DeleteOrderRequest) (*orderpb.DeleteOrderResponse, error) {
+	if err := s.wrap.DeleteOrder(ctx, req.GetId()); err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	s.logStatusTransition(ctx, req.GetId(), "existing", "deleted", orderpb.OrderService_DeleteOrder_FullMethodName)
+	return &orderpb.DeleteOrderResponse{}, nil
+}
+
+func (s *Server) AcceptOrder(ctx context.Context, req *orderpb.AcceptOrderRequest) (*orderpb.Order, error) {
+	return s.transition(ctx, req.GetId(), s.wrap.AcceptOrder, models.OrderStateAccepted, orderpb.OrderService_AcceptOrder_FullMethodName)
+}
+
+func (s *Server) DeliverOrder(ctx context.Context, req *orderpb.DeliverOrderRequest) (*orderpb.Order, error) {
+	return s.transition(ctx, req.GetId(), s.wrap.DeliverOrder, models.OrderStateDelivered, orderpb.OrderService_DeliverOrder_FullMethodName)
+}
+
+func (s *Server) ClientReturnOrder(ctx context.Context, req *orderpb.ClientReturnOrderRequest) (*orderpb.Order, error) {
+	return s.transition(ctx, req.GetId(), s.wrap.ClientReturnOrder, models.OrderStateClientRtn, orderpb.OrderService_ClientReturnOrder_FullMethodName)
+}
+
+func (s *Server) CourierReturnOrder(ctx context.Context, req *orderpb.CourierReturnOrderRequest) (*orderpb.Order, error) {
+	return s.transition(ctx, req.GetId(), s.wrap.CourierReturnOrder, models.OrderStateReturned, orderpb.OrderService_CourierReturnOrder_FullMethodName)
+}
+
+func (s *Server) transition(ctx context.Context, id string, apply func(context.Context, string) error, state models.OrderState, method string) (*orderpb.Order, error) {
+	if err := apply(ctx, id); err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	s.logStatusTransition(ctx, id, "", string(state), method)
+	o, err := s.wrap.GetOrderByID(ctx, id)
+	if err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	return orderToPB(o), nil
+}
+
+func (s *Server) ListActiveOrders(ctx context.Context, _ *orderpb.ListActiveOrdersRequest) (*orderpb.ListOrdersResponse, error) {
+	orders, err := s.wrap.ListActiveOrders(ctx)
+	if err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	return ordersToPB(orders), nil
+}
+
+func (s *Server) ListHistoryOrders(ctx context.Context, _ *orderpb.ListHistoryOrdersRequest) (*orderpb.ListOrdersResponse, error) {
+	orders, err := s.wrap.ListHistoryOrders(ctx)
+	if err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	return ordersToPB(orders), nil
+}
+
+// returnsCursor is the signed state behind ListReturns next_cursor. It is
+// bound to the recipient filter it was issued for.
+type returnsCursor struct {
+	Key         string `json:"k"`
+	ID          string `json:"id"`
+	RecipientID string `json:"r"`
+}
+
+func (s *Server) ListReturns(ctx context.Context, req *orderpb.ListReturnsRequest) (*orderpb.ListOrdersResponse, error) {
+	q := repository.ReturnsQuery{
+		RecipientID: req.GetRecipientId(),
+		Offset:      req.GetOffset(), //nolint:staticcheck // still honoured for old clients
+		Limit:       req.GetLimit(),
+	}
+	if req.GetCursor() != "" {
+		var c returnsCursor
+		if err := s.cursors.Decode(req.GetCursor(), &c); err != nil || c.RecipientID != q.RecipientID {
+			return nil, apperr.GRPCStatus(cursor.ErrInvalid)
+		}
+		q.After, q.Offset = &repository.Keyset{Key: c.Key, ID: c.ID}, 0
+	}
+	orders, more, err := s.wrap.GetReturns(ctx, q)
+	if err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	resp := ordersToPB(orders)
+	if more {
+		last := orders[len(orders)-1]
+		resp.NextCursor, err = s.cursors.Encode(returnsCursor{Key: repository.ReturnKey(last), ID: last.ID, RecipientID: q.RecipientID})
+		if err != nil {
+			return nil, apperr.GRPCStatus(err)
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) GetOrderHistory(ctx context.Context, req *orderpb.GetOrderHistoryRequest) (*orderpb.GetOrderHistoryResponse, error) {
+	events, err := s.wrap.OrderHistory(ctx, req.GetId())
+	if err != nil {
+		return nil, apperr.GRPCStatus(err)
+	}
+	return eventsToPB(events), nil
+}
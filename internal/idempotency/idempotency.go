@@ -0,0 +1,61 @@
+// Package idempotency stores the first response to a request made with an
+// Idempotency-Key, so that retries of the same request can be answered
+// without executing it again.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrNotFound = errors.New("idempotency key not found")
+
+// Record is the stored outcome of a request. Completed is false while the
+// first request is still being processed.
+type Record struct {
+	Principal   string
+	Key         string
+	RequestHash string
+	Completed   bool
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Store persists records keyed by principal and key.
+type Store interface {
+	// Reserve claims the key for a new request. If the key is already taken
+	// by an unexpired record, that record is returned and reserved is false.
+	Reserve(ctx context.Context, rec *Record) (existing *Record, reserved bool, err error)
+	// Complete stores the response of a reserved request.
+	Complete(ctx context.Context, principal, key string, statusCode int, contentType string, body []byte) error
+	// Release drops a reservation, letting the request be retried.
+	Release(ctx context.Context, principal, key string) error
+	// Purge deletes records that expired before now.
+	Purge(ctx context.Context, now time.Time) (int64, error)
+}
+
+// StartPurge deletes expired records every interval until ctx is cancelled.
+func StartPurge(ctx context.Context, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := store.Purge(ctx, time.Now())
+			if err != nil {
+				log.Printf("Error purging idempotency keys: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("Purged %d expired idempotency keys", n)
+			}
+		}
+	}
+}
@@ -19,6 +19,8 @@ func (h ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 	return nil
 }
 
+// StartSaramaConsumer consumes until ctx is cancelled and closes the consumer
+// group before returning.
 func StartSaramaConsumer(ctx context.Context, cfg *sarama.Config, brokers []string, groupID string, topics []string) {
 	config := cfg
 
@@ -28,7 +30,7 @@ func StartSaramaConsumer(ctx context.Context, cfg *sarama.Config, brokers []stri
 	}
 	defer func() {
 		if err := consumerGroup.Close(); err != nil {
-			log.Fatalf("Error closing consumer group: %v", err)
+			log.Printf("Error closing consumer group: %v", err)
 		}
 	}()
 
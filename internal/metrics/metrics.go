@@ -0,0 +1,295 @@
+// Package metrics is a minimal Prometheus text exposition implementation
+// with counters, gauges and histograms. Metrics created with the package
+// level constructors are registered in DefaultRegistry and served by Handler.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are latency buckets in seconds, the same as the Prometheus client defaults.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+var DefaultRegistry = NewRegistry()
+
+func (r *Registry) register(f *family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[f.name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name))
+	}
+	r.families[f.name] = f
+}
+
+// WriteTo writes every registered metric in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]*family, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, r.families[name])
+	}
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		f.write(cw)
+	}
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, cw.err
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) printf(format string, args ...interface{}) {
+	if c.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(c.w, format, args...)
+	c.n += int64(n)
+	c.err = err
+}
+
+// family is one metric name with its label names and a series per
+// combination of label values.
+type family struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+
+	value float64
+	fn    func() float64
+
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newFamily(r *Registry, name, help, kind string, labelNames []string) *family {
+	f := &family{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		series:     make(map[string]*series),
+	}
+	r.register(f)
+	return f
+}
+
+// get returns the series for labelValues, creating it on first use.
+// The caller holds f.mu.
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) write(w *countingWriter) {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	all := make([]series, 0, len(keys))
+	for _, k := range keys {
+		s := *f.series[k]
+		s.counts = append([]uint64(nil), s.counts...)
+		all = append(all, s)
+	}
+	f.mu.Unlock()
+
+	w.printf("# HELP %s %s\n", f.name, escapeHelp(f.help))
+	w.printf("# TYPE %s %s\n", f.name, f.kind)
+	for _, s := range all {
+		if f.kind == "histogram" {
+			f.writeHistogram(w, s)
+			continue
+		}
+		value := s.value
+		if s.fn != nil {
+			value = s.fn()
+		}
+		w.printf("%s%s %s\n", f.name, f.labels(s.labelValues, "", ""), formatFloat(value))
+	}
+}
+
+func (f *family) writeHistogram(w *countingWriter, s series) {
+	var cumulative uint64
+	for i, upper := range f.buckets {
+		cumulative += s.counts[i]
+		w.printf("%s_bucket%s %d\n", f.name, f.labels(s.labelValues, "le", formatFloat(upper)), cumulative)
+	}
+	w.printf("%s_bucket%s %d\n", f.name, f.labels(s.labelValues, "le", "+Inf"), s.count)
+	w.printf("%s_sum%s %s\n", f.name, f.labels(s.labelValues, "", ""), formatFloat(s.sum))
+	w.printf("%s_count%s %d\n", f.name, f.labels(s.labelValues, "", ""), s.count)
+}
+
+func (f *family) labels(values []string, extraName, extraValue string) string {
+	if len(values) == 0 && extraName == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, f.labelNames[i], escapeLabel(v)))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter is a monotonically increasing value per label combination.
+type Counter struct{ f *family }
+
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{f: newFamily(r, name, help, "counter", labelNames)}
+}
+
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labelNames...)
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.f.mu.Lock()
+	c.f.get(labelValues).value += v
+	c.f.mu.Unlock()
+}
+
+// Gauge is a value that can go up and down, or be computed at scrape time.
+type Gauge struct{ f *family }
+
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{f: newFamily(r, name, help, "gauge", labelNames)}
+}
+
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labelNames...)
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	s := g.f.get(labelValues)
+	s.value, s.fn = v, nil
+	g.f.mu.Unlock()
+}
+
+// SetFunc makes the series report fn() on every scrape.
+func (g *Gauge) SetFunc(fn func() float64, labelValues ...string) {
+	g.f.mu.Lock()
+	g.f.get(labelValues).fn = fn
+	g.f.mu.Unlock()
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct{ f *family }
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	f := newFamily(r, name, help, "histogram", labelNames)
+	f.buckets = append([]float64(nil), buckets...)
+	sort.Float64s(f.buckets)
+	return &Histogram{f: f}
+}
+
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labelNames...)
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.get(labelValues)
+	for i, upper := range h.f.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
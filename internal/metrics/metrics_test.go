@@ -0,0 +1,53 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/metrics"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := metrics.NewRegistry()
+	requests := r.NewCounter("http_requests_total", "Handled requests.", "route", "status")
+	depth := r.NewGauge("queue_depth", "Queued items.", "queue")
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1})
+
+	requests.Inc("/orders", "200")
+	requests.Add(2, "/orders", "200")
+	requests.Inc("/say \"hi\"", "500")
+	depth.SetFunc(func() float64 { return 7 }, "audit")
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(3)
+
+	var sb strings.Builder
+	_, err := r.WriteTo(&sb)
+	require.NoError(t, err)
+
+	want := `# HELP http_requests_total Handled requests.
+# TYPE http_requests_total counter
+http_requests_total{route="/orders",status="200"} 3
+http_requests_total{route="/say \"hi\"",status="500"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 3.55
+latency_seconds_count 3
+# HELP queue_depth Queued items.
+# TYPE queue_depth gauge
+queue_depth{queue="audit"} 7
+`
+	assert.Equal(t, want, sb.String())
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewCounter("dup_total", "Duplicate.")
+	assert.Panics(t, func() { r.NewGauge("dup_total", "Duplicate.") })
+}
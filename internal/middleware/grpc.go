@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"homework/internal/audit"
+	"homework/internal/auth"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthenticateUnaryInterceptor is the gRPC counterpart of Authenticate,
+// BearerAuthenticate and APIKeyAuthenticate. Credentials are read from the
+// "x-api-key" metadata key or from "authorization" in the same "Basic ..." or
+// "Bearer ..." form as the HTTP header.
+func AuthenticateUnaryInterceptor(authn *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if secret := metadataValue(ctx, "x-api-key"); secret != "" {
+			principal, err := authn.APIKeys.Authenticate(ctx, secret)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, "unauthorized")
+			}
+			return handler(auth.WithPrincipal(ctx, principal), req)
+		}
+		header := metadataValue(ctx, "authorization")
+		if token, ok := bearerToken(header); ok {
+			claims, err := authn.Tokens.Verify(ctx, token)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, "unauthorized")
+			}
+			return handler(auth.WithClaims(ctx, claims), req)
+		}
+		u, p, ok := parseBasicAuth(header)
+		if !ok {
+			return handler(ctx, req)
+		}
+		principal, err := authn.Users.Authenticate(u, p)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "unauthorized")
+		}
+		return handler(auth.WithPrincipal(ctx, principal), req)
+	}
+}
+
+// AuthorizeUnaryInterceptor is the gRPC counterpart of Authorize; perms is
+// keyed by full method name.
+func AuthorizeUnaryInterceptor(perms auth.MethodPermissions) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		perm, ok := perms[info.FullMethod]
+		if !ok {
+			return nil, status.Error(codes.PermissionDenied, "forbidden")
+		}
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "unauthorized")
+		}
+		if !principal.Can(perm) {
+			return nil, status.Error(codes.PermissionDenied, "forbidden")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuditUnaryInterceptor is the gRPC counterpart of AuditResponseMiddleware.
+func AuditUnaryInterceptor(auditPool *audit.AuditWorkerPool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		auditPool.Log(audit.AuditLog{
+			Timestamp: time.Now().UTC(),
+			Principal: auth.ActorFromContext(ctx),
+			Endpoint:  info.FullMethod,
+			Request:   "gRPC " + info.FullMethod,
+			Response:  status.Code(err).String(),
+			Message:   "Endpoint processed",
+		})
+		return resp, err
+	}
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func parseBasicAuth(header string) (string, string, bool) {
+	const prefix = "Basic "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+	u, p, ok := strings.Cut(string(decoded), ":")
+	return u, p, ok
+}
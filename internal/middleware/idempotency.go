@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework/internal/apperr"
+	"homework/internal/auth"
+	"homework/internal/idempotency"
+)
+
+const maxIdempotencyKeyLen = 255
+
+// DefaultMaxIdempotentBody is the usual bound of the request body hashed for
+// replay detection. Routes that accept larger bodies pass their own bound.
+const DefaultMaxIdempotentBody = 1 << 20
+
+var errBodyTooLarge = errors.New("request body is too large for an idempotent request")
+
+// recordingResponseWriter keeps a copy of the response so it can be stored.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// IdempotencyMiddleware makes mutating requests that carry an
+// Idempotency-Key header safe to retry. The first response is stored per
+// principal and key for ttl; a retry with the same payload gets that
+// response back, a retry with a different payload gets 422. Server errors are
+// not stored, so such requests can be retried for real. Neither are responses
+// marked Cache-Control: no-store, such as those carrying a new secret: a
+// retry of such a request runs again. Bodies over maxBody bytes get 413.
+func IdempotencyMiddleware(store idempotency.Store, ttl time.Duration, maxBody int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLen {
+				apperr.WriteStatus(w, r, http.StatusBadRequest, "invalid_idempotency_key",
+					fmt.Sprintf("Idempotency-Key is longer than %d characters", maxIdempotencyKeyLen))
+				return
+			}
+			hash, err := hashRequest(r, maxBody)
+			if errors.Is(err, errBodyTooLarge) {
+				apperr.WriteStatus(w, r, http.StatusRequestEntityTooLarge, "body_too_large", err.Error())
+				return
+			}
+			if err != nil {
+				apperr.WriteStatus(w, r, http.StatusBadRequest, "unreadable_body", err.Error())
+				return
+			}
+			now := time.Now().UTC()
+			rec := &idempotency.Record{
+				Principal:   auth.ActorFromContext(r.Context()),
+				Key:         key,
+				RequestHash: hash,
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(ttl),
+			}
+			existing, reserved, err := store.Reserve(r.Context(), rec)
+			if err != nil {
+				apperr.WriteProblem(w, r, fmt.Errorf("reserve idempotency key: %w", err))
+				return
+			}
+			if !reserved {
+				replay(w, r, existing, hash)
+				return
+			}
+			serveAndStore(w, r, next, store, rec)
+		})
+	}
+}
+
+func serveAndStore(w http.ResponseWriter, r *http.Request, next http.Handler, store idempotency.Store, rec *idempotency.Record) {
+	rw := &recordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(rw, r)
+
+	// The response is already sent, so the bookkeeping must not depend on
+	// the client still waiting.
+	ctx := context.WithoutCancel(r.Context())
+	if rw.status >= http.StatusInternalServerError || noStore(rw.Header()) {
+		if err := store.Release(ctx, rec.Principal, rec.Key); err != nil {
+			log.Printf("Error releasing idempotency key: %v", err)
+		}
+		return
+	}
+	err := store.Complete(ctx, rec.Principal, rec.Key, rw.status, rw.Header().Get("Content-Type"), rw.body.Bytes())
+	if err != nil {
+		log.Printf("Error storing idempotent response: %v", err)
+	}
+}
+
+// noStore reports whether the response forbids keeping a copy of it.
+func noStore(h http.Header) bool {
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+			return true
+		}
+	}
+	return false
+}
+
+func replay(w http.ResponseWriter, r *http.Request, rec *idempotency.Record, hash string) {
+	switch {
+	case rec.RequestHash != hash:
+		apperr.WriteStatus(w, r, http.StatusUnprocessableEntity, "idempotency_key_reused",
+			"Idempotency-Key was already used with a different request")
+	case !rec.Completed:
+		w.Header().Set("Retry-After", "1")
+		apperr.WriteStatus(w, r, http.StatusConflict, "idempotency_key_in_progress",
+			"a request with this Idempotency-Key is still in progress")
+	default:
+		if rec.ContentType != "" {
+			w.Header().Set("Content-Type", rec.ContentType)
+		}
+		w.Header().Set("Idempotent-Replayed", strconv.FormatBool(true))
+		w.WriteHeader(rec.StatusCode)
+		_, _ = w.Write(rec.Body)
+	}
+}
+
+// hashRequest fingerprints method, path and body, and leaves the body
+// readable for the handler.
+func hashRequest(r *http.Request, maxBody int64) (string, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		return "", fmt.Errorf("read request body: %w", err)
+	}
+	if int64(len(body)) > maxBody {
+		return "", errBodyTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
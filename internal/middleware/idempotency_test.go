@@ -0,0 +1,154 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/auth"
+	"homework/internal/idempotency"
+	"homework/internal/middleware"
+	"homework/internal/repository/memory"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	calls := 0
+	h := middleware.IdempotencyMiddleware(memory.NewIdempotencyRepository(), time.Hour, middleware.DefaultMaxIdempotentBody)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write(body)
+		}),
+	)
+	do := func(actor, key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		r = r.WithContext(auth.WithActor(r.Context(), actor))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	first := do("alice", "k1", `{"id":"1"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	replayed := do("alice", "k1", `{"id":"1"}`)
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Equal(t, `{"id":"1"}`, replayed.Body.String())
+	assert.Equal(t, "application/json", replayed.Header().Get("Content-Type"))
+	assert.Equal(t, "true", replayed.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 1, calls)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, do("alice", "k1", `{"id":"2"}`).Code)
+
+	// Keys are scoped to the principal.
+	assert.Equal(t, http.StatusCreated, do("bob", "k1", `{"id":"2"}`).Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyMiddlewareKeepsNoSecrets(t *testing.T) {
+	store := memory.NewIdempotencyRepository()
+	calls := 0
+	h := middleware.IdempotencyMiddleware(store, time.Hour, middleware.DefaultMaxIdempotentBody)(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"secret":"pk_live_123"}`))
+		}),
+	)
+	do := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(`{"name":"ci"}`))
+		r.Header.Set("Idempotency-Key", "k1")
+		r = r.WithContext(auth.WithActor(r.Context(), "admin"))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, do().Code)
+	_, reserved, err := store.Reserve(context.Background(), &idempotency.Record{
+		Principal: "admin", Key: "k1", ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.True(t, reserved, "the response with the secret must not be kept")
+	require.NoError(t, store.Release(context.Background(), "admin", "k1"))
+
+	retried := do()
+	assert.Equal(t, http.StatusCreated, retried.Code)
+	assert.Empty(t, retried.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls)
+}
+
+// failingStore is an idempotency store whose database is gone.
+type failingStore struct {
+	idempotency.Store
+}
+
+func (failingStore) Reserve(context.Context, *idempotency.Record) (*idempotency.Record, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func TestIdempotencyMiddlewareProblems(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	h := middleware.IdempotencyMiddleware(memory.NewIdempotencyRepository(), time.Hour, middleware.DefaultMaxIdempotentBody)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Block") != "" {
+				close(entered)
+				<-release
+			}
+			w.WriteHeader(http.StatusCreated)
+		}),
+	)
+	do := func(h http.Handler, key, body string, block bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		if block {
+			r.Header.Set("X-Block", "1")
+		}
+		r = r.WithContext(auth.WithActor(r.Context(), "alice"))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	assertProblem(t, do(h, strings.Repeat("k", 256), "{}", false), http.StatusBadRequest, "invalid_idempotency_key")
+	assertProblem(t, do(h, "big", strings.Repeat("x", 1<<20+1), false), http.StatusRequestEntityTooLarge, "body_too_large")
+
+	assert.Equal(t, http.StatusCreated, do(h, "k1", `{"id":"1"}`, false).Code)
+	assertProblem(t, do(h, "k1", `{"id":"2"}`, false), http.StatusUnprocessableEntity, "idempotency_key_reused")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		do(h, "k2", "{}", true)
+	}()
+	<-entered
+	assertProblem(t, do(h, "k2", "{}", false), http.StatusConflict, "idempotency_key_in_progress")
+	close(release)
+	<-done
+
+	broken := middleware.IdempotencyMiddleware(failingStore{}, time.Hour, middleware.DefaultMaxIdempotentBody)(h)
+	assertProblem(t, do(broken, "k3", "{}", false), http.StatusInternalServerError, "internal")
+}
+
+func TestIdempotencyMiddlewareBodyLimit(t *testing.T) {
+	created := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusCreated) })
+	h := middleware.IdempotencyMiddleware(memory.NewIdempotencyRepository(), time.Hour, 4<<20)(created)
+
+	r := httptest.NewRequest(http.MethodPost, "/orders:batch", strings.NewReader(strings.Repeat("x", 3<<20)))
+	r.Header.Set("Idempotency-Key", "k1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
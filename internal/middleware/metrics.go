@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"homework/internal/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounter("http_requests_total",
+		"HTTP requests by route, method and status.", "route", "method", "status")
+	httpDuration = metrics.NewHistogram("http_request_duration_seconds",
+		"HTTP request latency by route, method and status.", metrics.DefBuckets, "route", "method", "status")
+)
+
+// MetricsMiddleware counts requests and observes their latency. route is the
+// pattern the handler is registered under, which keeps label cardinality bounded.
+func MetricsMiddleware(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			lrw := &loggingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(lrw, r)
+			status := strconv.Itoa(lrw.status)
+			httpRequests.Inc(route, r.Method, status)
+			httpDuration.Observe(time.Since(start).Seconds(), route, r.Method, status)
+		})
+	}
+}
@@ -1,10 +1,14 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"homework/internal/apperr"
 	"homework/internal/audit"
+	"homework/internal/auth"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -25,17 +29,106 @@ func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-func BasicAuthMiddleware(user, pass string, methods ...string) func(http.Handler) http.Handler {
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streaming handlers can flush through the middleware.
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+// Authenticate resolves basic-auth credentials into a principal stored in the
+// request context. Requests without credentials pass through anonymously and
+// are rejected later by Authorize if the route needs a permission.
+func Authenticate(users *auth.UserStore) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if !methodInList(r.Method, methods) {
+			u, p, ok := r.BasicAuth()
+			if !ok {
 				next.ServeHTTP(w, r)
 				return
 			}
-			u, p, ok := r.BasicAuth()
-			if !ok || u != user || p != pass {
-				w.Header().Set("WWW-Authenticate", `Basic realm="orders"`)
-				http.Error(w, "unauthorized", http.StatusUnauthorized)
+			principal, err := users.Authenticate(u, p)
+			if err != nil {
+				unauthorized(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// BearerAuthenticate resolves "Authorization: Bearer <jwt>" into a principal.
+// It sits next to Authenticate, so both schemes are accepted on every route.
+func BearerAuthenticate(tokens *auth.TokenIssuer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r.Header.Get("Authorization"))
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			claims, err := tokens.Verify(r.Context(), token)
+			if err != nil {
+				if !errors.Is(err, auth.ErrInvalidToken) {
+					log.Printf("Error verifying bearer token: %v", err)
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="orders", error="invalid_token"`)
+				apperr.WriteStatus(w, r, http.StatusUnauthorized, "invalid_token", "bearer token is invalid, expired or revoked")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// APIKeyAuthenticate resolves the X-API-Key header of machine clients into a
+// principal whose permissions are the key's scopes.
+func APIKeyAuthenticate(keys *auth.APIKeys) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret := r.Header.Get("X-API-Key")
+			if secret == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			principal, err := keys.Authenticate(r.Context(), secret)
+			if err != nil {
+				if !errors.Is(err, auth.ErrInvalidAPIKey) {
+					log.Printf("Error checking api key: %v", err)
+				}
+				apperr.WriteStatus(w, r, http.StatusUnauthorized, "invalid_api_key", "API key is invalid, expired or revoked")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func bearerToken(header string) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return header[len(prefix):], true
+}
+
+// Authorize checks the authenticated principal against the permission the
+// request method requires on this route. Methods the route does not list are
+// rejected.
+func Authorize(perms auth.MethodPermissions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			perm, ok := perms[r.Method]
+			if !ok {
+				apperr.WriteStatus(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+				return
+			}
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if !ok {
+				unauthorized(w, r)
+				return
+			}
+			if !principal.Can(perm) {
+				apperr.WriteStatus(w, r, http.StatusForbidden, "forbidden", fmt.Sprintf("permission %s is required", perm))
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -43,13 +136,20 @@ func BasicAuthMiddleware(user, pass string, methods ...string) func(http.Handler
 	}
 }
 
-func LogMiddleware(auditPool *audit.AuditWorkerPool, methods ...string) func(http.Handler) http.Handler {
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="orders"`)
+	apperr.WriteStatus(w, r, http.StatusUnauthorized, "unauthorized", "valid credentials are required")
+}
+
+// LogMiddleware records every request that may modify data.
+func LogMiddleware(auditPool *audit.AuditWorkerPool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if methodInList(r.Method, methods) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
 				log.Printf("[%s] %s", r.Method, r.URL.Path)
 				auditPool.Log(audit.AuditLog{
 					Timestamp: time.Now().UTC(),
+					Principal: auth.ActorFromContext(r.Context()),
 					Endpoint:  r.URL.Path,
 					Request:   r.Method + " " + r.URL.String(),
 					Message:   "Request received",
@@ -67,6 +167,7 @@ func AuditResponseMiddleware(auditPool *audit.AuditWorkerPool) func(http.Handler
 			next.ServeHTTP(lrw, r)
 			auditPool.Log(audit.AuditLog{
 				Timestamp: time.Now().UTC(),
+				Principal: auth.ActorFromContext(r.Context()),
 				Endpoint:  r.URL.Path,
 				Request:   r.Method + " " + r.URL.String(),
 				Response:  fmt.Sprintf("%s (%d bytes)", http.StatusText(lrw.status), lrw.size),
@@ -75,12 +176,3 @@ func AuditResponseMiddleware(auditPool *audit.AuditWorkerPool) func(http.Handler
 		})
 	}
 }
-
-func methodInList(method string, methods []string) bool {
-	for _, m := range methods {
-		if m == method {
-			return true
-		}
-	}
-	return false
-}
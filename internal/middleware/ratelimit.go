@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homework/internal/apperr"
+	"homework/internal/auth"
+	"homework/internal/config"
+	"homework/internal/metrics"
+	"homework/internal/ratelimit"
+)
+
+var httpRateLimited = metrics.NewCounter("http_rate_limited_total",
+	"Requests rejected by the rate or concurrency limits.", "route", "limit")
+
+// RateLimitMiddleware applies the limits of one route. Reads (GET, HEAD) and
+// writes draw from separate token buckets keyed by the authenticated
+// principal, or by client IP for anonymous requests, so it has to run after
+// the authentication middlewares.
+func RateLimitMiddleware(route string, limits config.RateLimit) func(http.Handler) http.Handler {
+	var read, write *ratelimit.Limiter
+	if limits.ReadRate > 0 {
+		read = ratelimit.NewLimiter(limits.ReadRate, limits.ReadBurst)
+	}
+	if limits.WriteRate > 0 {
+		write = ratelimit.NewLimiter(limits.WriteRate, limits.WriteBurst)
+	}
+	var inFlight chan struct{}
+	if limits.MaxInFlight > 0 {
+		inFlight = make(chan struct{}, limits.MaxInFlight)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limiter, class := write, "write"
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				limiter, class = read, "read"
+			}
+			if limiter != nil && !allow(w, r, limiter, clientKey(r)) {
+				httpRateLimited.Inc(route, class)
+				return
+			}
+			if inFlight != nil {
+				select {
+				case inFlight <- struct{}{}:
+					defer func() { <-inFlight }()
+				default:
+					httpRateLimited.Inc(route, "in_flight")
+					w.Header().Set("Retry-After", "1")
+					apperr.WriteStatus(w, r, http.StatusServiceUnavailable, "too_many_in_flight", "too many concurrent requests")
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allow takes a token and sets the X-RateLimit-* headers, answering 429
+// when the bucket is empty.
+func allow(w http.ResponseWriter, r *http.Request, limiter *ratelimit.Limiter, key string) bool {
+	res := limiter.Allow(key)
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	if res.Allowed {
+		return true
+	}
+	h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	apperr.WriteStatus(w, r, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded")
+	return false
+}
+
+// AuthFailureLimit limits failed authentication per client IP. It goes in
+// front of the authentication middlewares: every 401 behind it takes a token
+// from the bucket of the client's IP, and once the bucket is empty the IP
+// gets 429 before its credentials are checked at all. Successful requests
+// cost nothing. Routes share failures, so guessing is limited across all of
+// them; a nil limiter disables the limit.
+func AuthFailureLimit(route string, failures *ratelimit.Limiter) func(http.Handler) http.Handler {
+	if failures == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + clientIP(r)
+			if res := failures.Peek(key); !res.Allowed {
+				httpRateLimited.Inc(route, "auth_failures")
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				apperr.WriteStatus(w, r, http.StatusTooManyRequests, "rate_limited", "too many failed authentication attempts")
+				return
+			}
+			lrw := &loggingResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(lrw, r)
+			if lrw.status == http.StatusUnauthorized {
+				failures.Allow(key)
+			}
+		})
+	}
+}
+
+func clientKey(r *http.Request) string {
+	if actor := auth.ActorFromContext(r.Context()); actor != "" {
+		return "principal:" + actor
+	}
+	return "ip:" + clientIP(r)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,99 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"homework/internal/apperr"
+	"homework/internal/auth"
+	"homework/internal/config"
+	"homework/internal/middleware"
+	"homework/internal/ratelimit"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := middleware.RateLimitMiddleware("/orders", config.RateLimit{
+		ReadRate: 1, ReadBurst: 5,
+		WriteRate: 1, WriteBurst: 1,
+	})(ok)
+
+	do := func(method, actor string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/orders", nil)
+		if actor != "" {
+			r = r.WithContext(auth.WithActor(r.Context(), actor))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(http.MethodPost, "alice")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = do(http.MethodPost, "alice")
+	assertProblem(t, w, http.StatusTooManyRequests, "rate_limited")
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Reads and other principals are limited separately.
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "alice").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "bob").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "").Code)
+}
+
+func TestRateLimitMiddlewareInFlight(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	h := middleware.RateLimitMiddleware("/orders:export", config.RateLimit{MaxInFlight: 1})(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			close(entered)
+			<-release
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders:export", nil))
+	}()
+	<-entered
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders:export", nil))
+	assertProblem(t, w, http.StatusServiceUnavailable, "too_many_in_flight")
+	close(release)
+	<-done
+}
+
+func TestAuthFailureLimit(t *testing.T) {
+	checked := 0
+	h := middleware.AuthFailureLimit("/orders", ratelimit.NewLimiter(0.01, 3))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			checked++
+			apperr.WriteStatus(w, r, http.StatusUnauthorized, "unauthorized", "invalid credentials")
+		}),
+	)
+	do := func(addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		r.RemoteAddr = addr
+		r.SetBasicAuth("admin", "guess")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		assertProblem(t, do("10.0.0.1:1000"), http.StatusUnauthorized, "unauthorized")
+	}
+	w := do("10.0.0.1:2000")
+	assertProblem(t, w, http.StatusTooManyRequests, "rate_limited")
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Equal(t, 3, checked, "credentials of a limited IP must not be checked")
+
+	// Other clients are not affected.
+	assertProblem(t, do("10.0.0.2:1000"), http.StatusUnauthorized, "unauthorized")
+}
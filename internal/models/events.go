@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+type OrderEventType string
+
+const (
+	OrderEventCreated      OrderEventType = "created"
+	OrderEventStateChanged OrderEventType = "state_changed"
+	OrderEventUpdated      OrderEventType = "updated"
+	OrderEventDeleted      OrderEventType = "deleted"
+)
+
+// FieldChange holds the JSON encoded old and new value of one order field.
+type FieldChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old"`
+	New   json.RawMessage `json:"new"`
+}
+
+// OrderEvent is one entry of an order timeline: a state transition or an
+// edit of its fields, together with who made it.
+type OrderEvent struct {
+	ID        int64          `json:"id"`
+	OrderID   string         `json:"order_id"`
+	Type      OrderEventType `json:"type"`
+	OldState  OrderState     `json:"old_state"`
+	NewState  OrderState     `json:"new_state"`
+	Changes   []FieldChange  `json:"changes"`
+	Actor     string         `json:"actor"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+type orderField struct {
+	name  string
+	value interface{}
+}
+
+// trackedFields lists the fields recorded in the order history.
+// LastStateChange is left out: it moves with every state timestamp.
+func (o *Order) trackedFields() []orderField {
+	pkgs := append([]string(nil), o.Packaging...)
+	sort.Strings(pkgs)
+	return []orderField{
+		{"recipient_id", o.RecipientID},
+		{"storage_deadline", normalizeTime(o.StorageDeadline)},
+		{"accepted_at", normalizeTime(o.AcceptedAt)},
+		{"delivered_at", normalizeTime(o.DeliveredAt)},
+		{"returned_at", normalizeTime(o.ReturnedAt)},
+		{"client_return_at", normalizeTime(o.ClientReturnAt)},
+		{"weight", o.Weight},
+		{"cost", o.Cost},
+		{"final_cost", o.FinalCost},
+		{"packaging", pkgs},
+		{"pickup_point_id", o.PickupPointID},
+	}
+}
+
+// normalizeTime drops what Postgres does not keep (sub-microsecond precision
+// and location) and reports zero times as null.
+func normalizeTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// DiffOrders returns the tracked fields that differ between before and after.
+// A nil before is treated as an empty order.
+func DiffOrders(before, after *Order) []FieldChange {
+	if before == nil {
+		before = &Order{}
+	}
+	oldFields, newFields := before.trackedFields(), after.trackedFields()
+	var changes []FieldChange
+	for i := range newFields {
+		oldValue, _ := json.Marshal(oldFields[i].value)
+		newValue, _ := json.Marshal(newFields[i].value)
+		if string(oldValue) == string(newValue) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: newFields[i].name, Old: oldValue, New: newValue})
+	}
+	return changes
+}
+
+// NewOrderEvent builds the event describing the move from before to after.
+func NewOrderEvent(eventType OrderEventType, before, after *Order, actor string) OrderEvent {
+	e := OrderEvent{Type: eventType, Actor: actor, CreatedAt: time.Now().UTC()}
+	if before != nil {
+		e.OrderID = before.ID
+		e.OldState = before.CurrentState()
+	}
+	if after != nil {
+		e.OrderID = after.ID
+		e.NewState = after.CurrentState()
+		e.Changes = DiffOrders(before, after)
+	}
+	return e
+}
@@ -1,20 +1,79 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
+	"homework/internal/apperr"
 	"homework/internal/packaging"
 )
 
 type OrderState string
 
 const (
+	OrderStateNew       OrderState = ""
 	OrderStateAccepted  OrderState = "accepted"
 	OrderStateDelivered OrderState = "delivered"
 	OrderStateReturned  OrderState = "returned"
 	OrderStateClientRtn OrderState = "client_rtn"
 )
 
+var (
+	ErrInvalidTransition = apperr.New(apperr.InvalidTransition, "invalid_transition", "invalid state transition")
+	ErrAlreadyInState    = apperr.New(apperr.InvalidTransition, "already_in_state", "order already in requested state")
+)
+
+// transitions lists the states reachable from every state. An accepted order
+// is either handed to the recipient or sent back to the courier once its
+// storage deadline has passed; a delivered order can only be returned by the
+// client, and a client return is finally handed back to the courier.
+var transitions = map[OrderState][]OrderState{
+	OrderStateNew:       {OrderStateAccepted},
+	OrderStateAccepted:  {OrderStateDelivered, OrderStateReturned},
+	OrderStateDelivered: {OrderStateClientRtn},
+	OrderStateClientRtn: {OrderStateReturned},
+	OrderStateReturned:  {},
+}
+
+// TransitionError describes a rejected state change. It matches
+// ErrInvalidTransition or ErrAlreadyInState with errors.Is and unwraps to
+// the more specific of the two.
+type TransitionError struct {
+	From OrderState
+	To   OrderState
+}
+
+func (e *TransitionError) Error() string {
+	from := e.From
+	if from == OrderStateNew {
+		from = "new"
+	}
+	return fmt.Sprintf("cannot move order from %s to %s", from, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	if target == ErrAlreadyInState {
+		return e.From == e.To
+	}
+	return target == ErrInvalidTransition
+}
+
+func (e *TransitionError) Unwrap() error {
+	if e.From == e.To {
+		return ErrAlreadyInState
+	}
+	return ErrInvalidTransition
+}
+
+func CanTransition(from, to OrderState) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
 type Order struct {
 	ID              string              `json:"id"`
 	RecipientID     string              `json:"recipient_id"`
@@ -26,17 +85,28 @@ type Order struct {
 	LastStateChange time.Time           `json:"last_state_change"`
 	Weight          float64             `json:"weight"`
 	Cost            float64             `json:"cost"`
+	FinalCost       float64             `json:"final_cost"`
 	Packaging       packaging.Packaging `json:"packaging"`
+	// PickupPointID is the pickup point holding the order. Empty means the
+	// order is not assigned to any point.
+	PickupPointID string `json:"pickup_point_id,omitempty"`
+	// Version is incremented by the repository on every write and is used
+	// for optimistic concurrency control.
+	Version int64 `json:"version"`
 }
 
-func (o *Order) UpdateState(newState OrderState) {
+// UpdateState moves the order to newState and stamps the matching timestamp.
+// It returns a *TransitionError if the move is not allowed from the current state.
+func (o *Order) UpdateState(newState OrderState) error {
+	current := o.CurrentState()
+	if !CanTransition(current, newState) {
+		return &TransitionError{From: current, To: newState}
+	}
 	now := time.Now().UTC()
 	o.LastStateChange = now
 	switch newState {
 	case OrderStateAccepted:
-		if o.AcceptedAt.IsZero() {
-			o.AcceptedAt = now
-		}
+		o.AcceptedAt = now
 	case OrderStateDelivered:
 		o.DeliveredAt = now
 	case OrderStateClientRtn:
@@ -44,6 +114,7 @@ func (o *Order) UpdateState(newState OrderState) {
 	case OrderStateReturned:
 		o.ReturnedAt = now
 	}
+	return nil
 }
 
 func (o *Order) CurrentState() OrderState {
@@ -59,5 +130,5 @@ func (o *Order) CurrentState() OrderState {
 	if !o.AcceptedAt.IsZero() {
 		return OrderStateAccepted
 	}
-	return ""
+	return OrderStateNew
 }
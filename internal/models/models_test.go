@@ -0,0 +1,73 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/models"
+	"homework/internal/packaging"
+)
+
+func TestUpdateStateTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  []models.OrderState
+		next  models.OrderState
+		valid bool
+	}{
+		{"accept new", nil, models.OrderStateAccepted, true},
+		{"deliver new", nil, models.OrderStateDelivered, false},
+		{"deliver accepted", []models.OrderState{models.OrderStateAccepted}, models.OrderStateDelivered, true},
+		{"expire accepted", []models.OrderState{models.OrderStateAccepted}, models.OrderStateReturned, true},
+		{"client return accepted", []models.OrderState{models.OrderStateAccepted}, models.OrderStateClientRtn, false},
+		{"client return delivered", []models.OrderState{models.OrderStateAccepted, models.OrderStateDelivered}, models.OrderStateClientRtn, true},
+		{"courier return delivered", []models.OrderState{models.OrderStateAccepted, models.OrderStateDelivered}, models.OrderStateReturned, false},
+		{"courier return client return", []models.OrderState{models.OrderStateAccepted, models.OrderStateDelivered, models.OrderStateClientRtn}, models.OrderStateReturned, true},
+		{"deliver returned", []models.OrderState{models.OrderStateAccepted, models.OrderStateReturned}, models.OrderStateDelivered, false},
+		{"accept twice", []models.OrderState{models.OrderStateAccepted}, models.OrderStateAccepted, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &models.Order{}
+			for _, s := range tt.path {
+				assert.NoError(t, o.UpdateState(s))
+			}
+			err := o.UpdateState(tt.next)
+			if tt.valid {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.next, o.CurrentState())
+				return
+			}
+			assert.ErrorIs(t, err, models.ErrInvalidTransition)
+		})
+	}
+}
+
+func TestUpdateStateAlreadyInState(t *testing.T) {
+	o := &models.Order{}
+	assert.NoError(t, o.UpdateState(models.OrderStateAccepted))
+	acceptedAt := o.AcceptedAt
+
+	err := o.UpdateState(models.OrderStateAccepted)
+	assert.ErrorIs(t, err, models.ErrAlreadyInState)
+	assert.Equal(t, acceptedAt, o.AcceptedAt)
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	accepted := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	o := &models.Order{ID: "ord1", RecipientID: "user1", Weight: 2, Packaging: packaging.Packaging{"box"}, AcceptedAt: accepted}
+
+	require.NoError(t, models.ApplyMergePatch(o, []byte(`{"storage_deadline":"2025-07-01T12:00:00Z","packaging":null}`)))
+	assert.Equal(t, time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC), o.StorageDeadline)
+	assert.Nil(t, o.Packaging)
+	assert.Equal(t, "user1", o.RecipientID)
+	assert.Equal(t, accepted, o.AcceptedAt)
+
+	assert.ErrorIs(t, models.ApplyMergePatch(o, []byte(`{"weight":3,"accepted_at":null}`)), models.ErrReadOnlyField)
+	assert.ErrorIs(t, models.ApplyMergePatch(o, []byte(`{"weight":"heavy"}`)), models.ErrInvalidPatch)
+	assert.ErrorIs(t, models.ApplyMergePatch(o, []byte(`[]`)), models.ErrInvalidPatch)
+	assert.Equal(t, 2.0, o.Weight)
+}
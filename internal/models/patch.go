@@ -0,0 +1,70 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"homework/internal/apperr"
+)
+
+var (
+	// ErrInvalidPatch is returned for a merge-patch document that is not a
+	// JSON object or whose values have the wrong type.
+	ErrInvalidPatch = apperr.New(apperr.Validation, "invalid_patch", "invalid merge patch")
+	// ErrReadOnlyField is returned when a merge patch touches a field that
+	// can only change through a state transition, or is never editable.
+	ErrReadOnlyField = apperr.New(apperr.Validation, "read_only_field", "field is not editable")
+)
+
+// patchableFields lists the order fields a merge patch may change. State
+// timestamps are deliberately absent: they move only through transitions.
+var patchableFields = map[string]func(o *Order, raw json.RawMessage) error{
+	"recipient_id":     func(o *Order, raw json.RawMessage) error { return patchValue(raw, &o.RecipientID) },
+	"storage_deadline": func(o *Order, raw json.RawMessage) error { return patchValue(raw, &o.StorageDeadline) },
+	"weight":           func(o *Order, raw json.RawMessage) error { return patchValue(raw, &o.Weight) },
+	"cost":             func(o *Order, raw json.RawMessage) error { return patchValue(raw, &o.Cost) },
+	"packaging":        func(o *Order, raw json.RawMessage) error { return patchValue(raw, &o.Packaging) },
+	"pickup_point_id":  func(o *Order, raw json.RawMessage) error { return patchValue(raw, &o.PickupPointID) },
+}
+
+// ApplyMergePatch applies an RFC 7386 merge-patch document to o. A null
+// member resets the field to its zero value. o is left untouched if the
+// patch is rejected.
+func ApplyMergePatch(o *Order, doc []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &members); err != nil || members == nil {
+		return fmt.Errorf("%w: document must be a JSON object", ErrInvalidPatch)
+	}
+	names := make([]string, 0, len(members))
+	for name := range members {
+		if _, ok := patchableFields[name]; !ok {
+			return fmt.Errorf("%s: %w", name, ErrReadOnlyField)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	patched := *o
+	for _, name := range names {
+		if err := patchableFields[name](&patched, members[name]); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidPatch, name, err)
+		}
+	}
+	*o = patched
+	return nil
+}
+
+// patchValue decodes raw into a fresh value before assigning it, so a slice
+// field never reuses the backing array of the order being patched.
+func patchValue[T any](raw json.RawMessage, dst *T) error {
+	var v T
+	if !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+	}
+	*dst = v
+	return nil
+}
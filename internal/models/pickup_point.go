@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// PickupPoint is a place where recipients collect their orders. Capacity is
+// the number of shelf places; zero means the point is not limited.
+type PickupPoint struct {
+	ID           string    `json:"id"`
+	Address      string    `json:"address"`
+	WorkingHours string    `json:"working_hours"`
+	Capacity     int       `json:"capacity"`
+	CreatedAt    time.Time `json:"created_at"`
+	// Occupied counts the orders on the shelf: accepted and not yet handed
+	// out, or returned by the client and waiting for the courier. It is
+	// computed and never stored.
+	Occupied int `json:"occupied"`
+}
+
+// OnShelf reports whether the order takes a place at its pickup point.
+func (o *Order) OnShelf() bool {
+	state := o.CurrentState()
+	return state == OrderStateAccepted || state == OrderStateClientRtn
+}
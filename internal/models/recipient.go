@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"homework/internal/apperr"
+)
+
+type RecipientStatus string
+
+const (
+	RecipientActive  RecipientStatus = "active"
+	RecipientBlocked RecipientStatus = "blocked"
+)
+
+// ErrRecipientBlocked refuses new parcels for a blocked recipient.
+var ErrRecipientBlocked = apperr.New(apperr.Conflict, "recipient_blocked", "recipient is blocked")
+
+// Recipient is the person orders are addressed to. Recipients created on the
+// fly by an order only carry their ID until the contact details are filled in.
+type Recipient struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Phone     string          `json:"phone"`
+	Email     string          `json:"email"`
+	Status    RecipientStatus `json:"status"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Valid reports whether s is a known status.
+func (s RecipientStatus) Valid() bool {
+	return s == RecipientActive || s == RecipientBlocked
+}
@@ -0,0 +1,95 @@
+package orderio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+	"homework/internal/packaging"
+)
+
+// ReadCSV decodes orders from CSV with a header row naming the columns.
+// Columns may come in any order; packaging types are separated by "+", as in
+// "box+film". Only a missing or unreadable header fails the whole input;
+// problems in a record are reported on its Row.
+func ReadCSV(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, apperr.NewValidation(apperr.Field("header", "cannot read CSV header: %v", err))
+	}
+	index, err := columnIndex(header)
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, Row{Line: line, Err: fmt.Errorf("%w: %v", errMalformedRecord, err)})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, decodeRecord(line, record, index))
+	}
+}
+
+func columnIndex(header []string) (map[string]int, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	var missing []apperr.FieldError
+	for _, name := range Columns {
+		if _, ok := index[name]; !ok && !optionalColumns[name] {
+			missing = append(missing, apperr.Field(name, "column is missing from the CSV header"))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, apperr.NewValidation(missing...)
+	}
+	return index, nil
+}
+
+func decodeRecord(line int, record []string, index map[string]int) Row {
+	field := func(name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	o := &models.Order{ID: field("id"), RecipientID: field("recipient_id"), PickupPointID: field("pickup_point_id")}
+	var errs []apperr.FieldError
+	var err error
+	if o.StorageDeadline, err = time.Parse(time.RFC3339, field("storage_deadline")); err != nil {
+		errs = append(errs, apperr.Field("storage_deadline", "must be an RFC 3339 timestamp"))
+	}
+	if o.Weight, err = strconv.ParseFloat(field("weight"), 64); err != nil {
+		errs = append(errs, apperr.Field("weight", "must be a number"))
+	}
+	if o.Cost, err = strconv.ParseFloat(field("cost"), 64); err != nil {
+		errs = append(errs, apperr.Field("cost", "must be a number"))
+	}
+	if pkg := field("packaging"); pkg != "" {
+		o.Packaging = packaging.Packaging{pkg}
+	}
+	if len(errs) > 0 {
+		return Row{Line: line, Err: apperr.NewValidation(errs...)}
+	}
+	return Row{Line: line, Order: o}
+}
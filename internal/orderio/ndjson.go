@@ -0,0 +1,62 @@
+package orderio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"homework/internal/models"
+	"homework/internal/packaging"
+)
+
+// maxLineBytes bounds a single NDJSON record.
+const maxLineBytes = 64 << 10
+
+// record holds the fields of an order that bulk formats carry. State
+// timestamps are left out: imported orders always start as new.
+type record struct {
+	ID              string              `json:"id"`
+	RecipientID     string              `json:"recipient_id"`
+	StorageDeadline time.Time           `json:"storage_deadline"`
+	Weight          float64             `json:"weight"`
+	Cost            float64             `json:"cost"`
+	Packaging       packaging.Packaging `json:"packaging"`
+	PickupPointID   string              `json:"pickup_point_id"`
+}
+
+// ReadNDJSON decodes one JSON order per line, skipping blank lines. A line
+// that is not a valid order is reported on its Row.
+func ReadNDJSON(r io.Reader) ([]Row, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 4096), maxLineBytes)
+	var rows []Row
+	line := 0
+	for sc.Scan() {
+		data := bytes.TrimSpace(sc.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		line++
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			rows = append(rows, Row{Line: line, Err: fmt.Errorf("%w: %v", errMalformedRecord, err)})
+			continue
+		}
+		rows = append(rows, Row{Line: line, Order: &models.Order{
+			ID:              rec.ID,
+			RecipientID:     rec.RecipientID,
+			StorageDeadline: rec.StorageDeadline,
+			Weight:          rec.Weight,
+			Cost:            rec.Cost,
+			Packaging:       rec.Packaging,
+			PickupPointID:   rec.PickupPointID,
+		}})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read NDJSON: %w", err)
+	}
+	return rows, nil
+}
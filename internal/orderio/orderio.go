@@ -0,0 +1,30 @@
+// Package orderio reads and writes orders in the bulk formats of the HTTP
+// API: CSV and newline-delimited JSON.
+package orderio
+
+import (
+	"homework/internal/apperr"
+	"homework/internal/models"
+)
+
+const (
+	ContentTypeCSV    = "text/csv"
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// Columns are the CSV columns of an order, in the order they are written.
+var Columns = []string{"id", "recipient_id", "storage_deadline", "weight", "cost", "packaging", "pickup_point_id"}
+
+// optionalColumns may be left out of a CSV header.
+var optionalColumns = map[string]bool{"packaging": true, "pickup_point_id": true}
+
+// Row is one decoded input record. Line is its 1-based position among the
+// data records. Err is set, and Order is nil, when the record could not be
+// decoded.
+type Row struct {
+	Line  int
+	Order *models.Order
+	Err   error
+}
+
+var errMalformedRecord = apperr.New(apperr.Validation, "malformed_record", "malformed record")
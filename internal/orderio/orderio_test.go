@@ -0,0 +1,81 @@
+package orderio_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+	"homework/internal/orderio"
+	"homework/internal/packaging"
+)
+
+func TestReadCSV(t *testing.T) {
+	input := `recipient_id,id,storage_deadline,weight,cost,packaging
+user1,ord1,2030-01-01T00:00:00Z,2.5,100,box+film
+user2,ord2,tomorrow,heavy,100,
+`
+	rows, err := orderio.ReadCSV(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	require.NoError(t, rows[0].Err)
+	assert.Equal(t, "ord1", rows[0].Order.ID)
+	assert.Equal(t, "user1", rows[0].Order.RecipientID)
+	assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), rows[0].Order.StorageDeadline)
+	assert.Equal(t, []string{"box", "film"}, rows[0].Order.Packaging.Names())
+
+	assert.Equal(t, 2, rows[1].Line)
+	assert.Nil(t, rows[1].Order)
+	assert.Len(t, apperr.From(rows[1].Err).Fields, 2)
+
+	_, err = orderio.ReadCSV(strings.NewReader("id,weight\n"))
+	assert.Equal(t, apperr.Validation, apperr.KindOf(err))
+}
+
+func TestReadNDJSON(t *testing.T) {
+	input := `{"id":"ord1","recipient_id":"user1","storage_deadline":"2030-01-01T00:00:00Z","weight":2,"cost":100,"accepted_at":"2024-01-01T00:00:00Z"}
+
+{"id":
+`
+	rows, err := orderio.ReadNDJSON(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.NoError(t, rows[0].Err)
+	assert.True(t, rows[0].Order.AcceptedAt.IsZero())
+	assert.Equal(t, 2, rows[1].Line)
+	assert.Equal(t, "malformed_record", apperr.From(rows[1].Err).Code)
+}
+
+func TestWriters(t *testing.T) {
+	o := &models.Order{
+		ID:              "ord1",
+		RecipientID:     "user1",
+		StorageDeadline: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		AcceptedAt:      time.Date(2029, 12, 1, 0, 0, 0, 0, time.UTC),
+		Weight:          2.5,
+		Cost:            100,
+		FinalCost:       121,
+		Packaging:       packaging.Packaging{"box", "film"},
+		Version:         2,
+		PickupPointID:   "pvz1",
+	}
+
+	var csvOut strings.Builder
+	w := orderio.NewCSVWriter(&csvOut)
+	require.NoError(t, w.Write(o))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, strings.Join(orderio.ExportColumns, ",")+"\n"+
+		"ord1,user1,accepted,2030-01-01T00:00:00Z,2029-12-01T00:00:00Z,,,,,2.5,100,121,box+film,2,pvz1\n", csvOut.String())
+
+	var jsonOut strings.Builder
+	w = orderio.NewNDJSONWriter(&jsonOut)
+	require.NoError(t, w.Write(&models.Order{ID: "ord2"}))
+	require.NoError(t, w.Flush())
+	assert.Contains(t, jsonOut.String(), `"state":"new"`)
+	assert.True(t, strings.HasSuffix(jsonOut.String(), "}\n"))
+}
@@ -0,0 +1,112 @@
+package orderio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework/internal/models"
+	"homework/internal/packaging"
+)
+
+// ExportColumns are the CSV columns of an exported order.
+var ExportColumns = []string{
+	"id", "recipient_id", "state", "storage_deadline",
+	"accepted_at", "delivered_at", "returned_at", "client_return_at", "last_state_change",
+	"weight", "cost", "final_cost", "packaging", "version", "pickup_point_id",
+}
+
+// Writer encodes a stream of orders. Output is buffered until Flush.
+type Writer interface {
+	Write(o *models.Order) error
+	Flush() error
+}
+
+// StateName is the exported name of a state; the new state is "new" rather
+// than the empty string.
+func StateName(s models.OrderState) string {
+	if s == models.OrderStateNew {
+		return "new"
+	}
+	return string(s)
+}
+
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+// NewCSVWriter writes orders as CSV with an ExportColumns header.
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) header() error {
+	if c.wroteHeader {
+		return nil
+	}
+	c.wroteHeader = true
+	return c.w.Write(ExportColumns)
+}
+
+func (c *csvWriter) Write(o *models.Order) error {
+	if err := c.header(); err != nil {
+		return err
+	}
+	return c.w.Write([]string{
+		o.ID, o.RecipientID, StateName(o.CurrentState()), formatTime(o.StorageDeadline),
+		formatTime(o.AcceptedAt), formatTime(o.DeliveredAt), formatTime(o.ReturnedAt),
+		formatTime(o.ClientReturnAt), formatTime(o.LastStateChange),
+		formatFloat(o.Weight), formatFloat(o.Cost), formatFloat(o.FinalCost),
+		strings.Join(o.Packaging.Names(), packaging.Separator), strconv.FormatInt(o.Version, 10),
+		o.PickupPointID,
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	if err := c.header(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// exportRecord adds the computed state to the JSON of an order.
+type exportRecord struct {
+	*models.Order
+	State string `json:"state"`
+}
+
+// NewNDJSONWriter writes one JSON object per order and line.
+func NewNDJSONWriter(w io.Writer) Writer {
+	buf := bufio.NewWriter(w)
+	return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (n *ndjsonWriter) Write(o *models.Order) error {
+	return n.enc.Encode(exportRecord{Order: o, State: StateName(o.CurrentState())})
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return n.buf.Flush()
+}
@@ -0,0 +1,155 @@
+package packaging
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"homework/internal/apperr"
+)
+
+// Separator joins several packaging types in one value, e.g. "box+film".
+const Separator = "+"
+
+var ErrInvalidPackaging = apperr.New(apperr.Validation, "invalid_packaging", "invalid packaging")
+
+// Strategy encapsulates the rules of a single packaging type: the weight it
+// can hold and the surcharge it adds to the order cost. Add-on strategies
+// (like film) cannot be used on their own and wrap a base packaging.
+type Strategy interface {
+	Name() string
+	AddOn() bool
+	Validate(weight float64) error
+	Surcharge() float64
+}
+
+type Bag struct{}
+
+func (Bag) Name() string       { return "bag" }
+func (Bag) AddOn() bool        { return false }
+func (Bag) Surcharge() float64 { return 5 }
+
+func (b Bag) Validate(weight float64) error {
+	return checkWeight(b.Name(), weight, 10)
+}
+
+type Box struct{}
+
+func (Box) Name() string       { return "box" }
+func (Box) AddOn() bool        { return false }
+func (Box) Surcharge() float64 { return 20 }
+
+func (b Box) Validate(weight float64) error {
+	return checkWeight(b.Name(), weight, 30)
+}
+
+type Film struct{}
+
+func (Film) Name() string             { return "film" }
+func (Film) AddOn() bool              { return true }
+func (Film) Surcharge() float64       { return 1 }
+func (Film) Validate(_ float64) error { return nil }
+
+func checkWeight(name string, weight, limit float64) error {
+	if weight >= limit {
+		return fmt.Errorf("%w: %s holds less than %g kg, got %g", ErrInvalidPackaging, name, limit, weight)
+	}
+	return nil
+}
+
+// Registry resolves packaging names to strategies. New packaging types are
+// added with Register and become available to every user of the registry.
+type Registry struct {
+	mu         sync.RWMutex
+	strategies map[string]Strategy
+}
+
+func NewRegistry(strategies ...Strategy) *Registry {
+	r := &Registry{strategies: make(map[string]Strategy, len(strategies))}
+	for _, s := range strategies {
+		r.Register(s)
+	}
+	return r
+}
+
+var Default = NewRegistry(Bag{}, Box{}, Film{})
+
+func Register(s Strategy) {
+	Default.Register(s)
+}
+
+func (r *Registry) Register(s Strategy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strategies[s.Name()] = s
+}
+
+func (r *Registry) Lookup(name string) (Strategy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.strategies[name]
+	return s, ok
+}
+
+// Resolve splits p into single packaging types and checks how they combine:
+// at most one base packaging (so no bag inside a box), add-ons only on top of
+// a base, and no type repeated.
+func (r *Registry) Resolve(p Packaging) ([]Strategy, error) {
+	var (
+		result []Strategy
+		base   Strategy
+		seen   = make(map[string]bool)
+	)
+	for _, name := range p.Names() {
+		s, ok := r.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidPackaging, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s used twice", ErrInvalidPackaging, name)
+		}
+		seen[name] = true
+		if !s.AddOn() {
+			if base != nil {
+				return nil, fmt.Errorf("%w: cannot combine %s with %s", ErrInvalidPackaging, base.Name(), name)
+			}
+			base = s
+		}
+		result = append(result, s)
+	}
+	if base == nil && len(result) > 0 {
+		return nil, fmt.Errorf("%w: %s is only an add-on", ErrInvalidPackaging, result[0].Name())
+	}
+	return result, nil
+}
+
+// FinalCost validates p against the order weight and returns cost plus the
+// surcharges of every packaging type.
+func (r *Registry) FinalCost(p Packaging, weight, cost float64) (float64, error) {
+	strategies, err := r.Resolve(p)
+	if err != nil {
+		return 0, err
+	}
+	total := cost
+	for _, s := range strategies {
+		if err := s.Validate(weight); err != nil {
+			return 0, err
+		}
+		total += s.Surcharge()
+	}
+	return total, nil
+}
+
+// Names flattens p into single packaging types, splitting combined values
+// such as "box+film".
+func (p Packaging) Names() []string {
+	var names []string
+	for _, v := range p {
+		for _, name := range strings.Split(v, Separator) {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,58 @@
+package packaging_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"homework/internal/packaging"
+)
+
+func TestFinalCost(t *testing.T) {
+	tests := []struct {
+		name   string
+		pkg    packaging.Packaging
+		weight float64
+		want   float64
+		valid  bool
+	}{
+		{"no packaging", nil, 50, 100, true},
+		{"bag", packaging.Packaging{"bag"}, 9, 105, true},
+		{"bag too heavy", packaging.Packaging{"bag"}, 10, 0, false},
+		{"box", packaging.Packaging{"box"}, 29, 120, true},
+		{"box too heavy", packaging.Packaging{"box"}, 30, 0, false},
+		{"box with film", packaging.Packaging{"box+film"}, 5, 121, true},
+		{"bag with film as list", packaging.Packaging{"bag", "film"}, 5, 106, true},
+		{"film alone", packaging.Packaging{"film"}, 1, 0, false},
+		{"bag inside box", packaging.Packaging{"box+bag"}, 1, 0, false},
+		{"film twice", packaging.Packaging{"box+film+film"}, 1, 0, false},
+		{"unknown", packaging.Packaging{"crate"}, 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := packaging.Default.FinalCost(tt.pkg, tt.weight, 100)
+			if !tt.valid {
+				assert.ErrorIs(t, err, packaging.ErrInvalidPackaging)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+type crate struct{}
+
+func (crate) Name() string             { return "crate" }
+func (crate) AddOn() bool              { return false }
+func (crate) Surcharge() float64       { return 50 }
+func (crate) Validate(_ float64) error { return nil }
+
+func TestRegistryRegister(t *testing.T) {
+	r := packaging.NewRegistry(packaging.Film{})
+	r.Register(crate{})
+
+	got, err := r.FinalCost(packaging.Packaging{"crate+film"}, 500, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 61.0, got)
+}
@@ -6,9 +6,19 @@ import (
 	"time"
 
 	"homework/internal/kafka"
+	"homework/internal/metrics"
 	"homework/internal/repository"
 )
 
+var (
+	tasksPublished = metrics.NewCounter("outbox_tasks_published_total",
+		"Outbox tasks published to Kafka.")
+	tasksFailed = metrics.NewCounter("outbox_tasks_failed_total",
+		"Outbox publish attempts that failed, by resulting task status.", "status")
+	outboxLag = metrics.NewGauge("outbox_lag_seconds",
+		"Age of the oldest task in the most recently fetched batch.")
+)
+
 type TaskProcessor struct {
 	repo         repository.TaskRepository
 	producer     kafka.SaramaProducer
@@ -31,6 +41,9 @@ func NewTaskProcessor(repo repository.TaskRepository, producer kafka.SaramaProdu
 	}
 }
 
+// Start polls for pending tasks until ctx is cancelled. A batch that is
+// already being published runs to completion, so Start returns only once
+// every claimed task has been either deleted or marked as failed.
 func (p *TaskProcessor) Start(ctx context.Context) {
 	ticker := time.NewTicker(p.pollInterval)
 	defer ticker.Stop()
@@ -39,7 +52,7 @@ func (p *TaskProcessor) Start(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			p.processPendingTasks(ctx)
+			p.processPendingTasks(context.WithoutCancel(ctx))
 			ticker.Reset(p.pollInterval)
 		}
 	}
@@ -51,6 +64,7 @@ func (p *TaskProcessor) processPendingTasks(ctx context.Context) {
 		log.Printf("Error fetching pending tasks: %v", err)
 		return
 	}
+	outboxLag.Set(oldestTaskAge(tasks, time.Now()).Seconds())
 	for _, task := range tasks {
 		err = p.repo.MarkTaskProcessing(ctx, task.ID)
 		if err != nil {
@@ -63,6 +77,7 @@ func (p *TaskProcessor) processPendingTasks(ctx context.Context) {
 			p.update(ctx, task, err)
 			continue
 		}
+		tasksPublished.Inc()
 		log.Printf("Task %d processed and published to Kafka", task.ID)
 		err = p.repo.DeleteTask(ctx, task.ID)
 		if err != nil {
@@ -79,6 +94,7 @@ func (p *TaskProcessor) update(ctx context.Context, task *repository.Task, err e
 	} else {
 		newStatus = repository.TaskStatusFailed
 	}
+	tasksFailed.Inc(string(newStatus))
 	nextAttempt := time.Now().Add(p.retryDelay)
 	errUpd := p.repo.UpdateTaskFailure(ctx, task.ID, newAttempt, newStatus, nextAttempt)
 	if errUpd != nil {
@@ -86,3 +102,13 @@ func (p *TaskProcessor) update(ctx context.Context, task *repository.Task, err e
 	}
 	log.Printf("Failed to publish task %d: %v", task.ID, err)
 }
+
+func oldestTaskAge(tasks []*repository.Task, now time.Time) time.Duration {
+	var oldest time.Duration
+	for _, task := range tasks {
+		if age := now.Sub(task.CreatedAt); age > oldest {
+			oldest = age
+		}
+	}
+	return oldest
+}
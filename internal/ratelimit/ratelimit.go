@@ -0,0 +1,109 @@
+// Package ratelimit implements per-key token buckets.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled completely are
+// dropped, so idle clients do not accumulate in memory.
+const sweepInterval = time.Minute
+
+// Result describes the outcome of Allow, for the X-RateLimit-* headers.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait for the next token when not allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter hands out rate tokens per second per key, allowing bursts of up
+// to burst requests.
+type Limiter struct {
+	rate  float64
+	burst int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	return newLimiter(rate, burst, time.Now)
+}
+
+func newLimiter(rate float64, burst int, now func() time.Time) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:      rate,
+		burst:     burst,
+		now:       now,
+		buckets:   make(map[string]*bucket),
+		lastSweep: now(),
+	}
+}
+
+// Allow takes a token from the bucket of key if one is available.
+func (l *Limiter) Allow(key string) Result {
+	return l.take(key, 1)
+}
+
+// Peek reports whether Allow would succeed for key without taking a token.
+func (l *Limiter) Peek(key string) Result {
+	return l.take(key, 0)
+}
+
+func (l *Limiter) take(key string, n float64) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	res := Result{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens -= n
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.duration(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = l.duration(float64(l.burst) - b.tokens)
+	return res
+}
+
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newLimiter(2, 3, func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow("a").Allowed)
+	}
+	res := l.Allow("a")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	// Other keys have their own bucket.
+	assert.True(t, l.Allow("b").Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	res = l.Allow("a")
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 3, res.Limit)
+}
+
+func TestLimiterSweepsFullBuckets(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newLimiter(1, 1, func() time.Time { return now })
+	l.Allow("a")
+
+	now = now.Add(2 * sweepInterval)
+	l.Allow("b")
+	_, ok := l.buckets["a"]
+	assert.False(t, ok)
+}
+
+func TestLimiterPeekTakesNoToken(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newLimiter(1, 1, func() time.Time { return now })
+
+	assert.True(t, l.Peek("a").Allowed)
+	assert.True(t, l.Allow("a").Allowed)
+	res := l.Peek("a")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+}
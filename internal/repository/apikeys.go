@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"homework/internal/auth"
+)
+
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, created_by, created_at, expires_at, last_used_at, revoked_at`
+
+type PostgresAPIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresAPIKeyRepository(db *sql.DB) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{db: db}
+}
+
+func scanAPIKey(row rowScanner) (*auth.APIKey, error) {
+	var k auth.APIKey
+	var scopes []string
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Hash, pq.Array(&scopes), &k.CreatedBy, &k.CreatedAt,
+		timeScanner{&k.ExpiresAt}, timeScanner{&k.LastUsedAt}, timeScanner{&k.RevokedAt})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range scopes {
+		k.Scopes = append(k.Scopes, auth.Permission(s))
+	}
+	return &k, nil
+}
+
+func scopeStrings(scopes []auth.Permission) []string {
+	out := make([]string, len(scopes))
+	for i, s := range scopes {
+		out[i] = string(s)
+	}
+	return out
+}
+
+func (r *PostgresAPIKeyRepository) CreateAPIKey(ctx context.Context, k *auth.APIKey) error {
+	query := `INSERT INTO api_keys (name, prefix, key_hash, scopes, created_by, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err := r.db.QueryRowContext(ctx, query, k.Name, k.Prefix, k.Hash, pq.Array(scopeStrings(k.Scopes)),
+		k.CreatedBy, k.CreatedAt, nullTime(k.ExpiresAt)).Scan(&k.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAPIKey: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresAPIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*auth.APIKey, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE prefix = $1`, prefix)
+	k, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, auth.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetAPIKeyByPrefix: %w", err)
+	}
+	return k, nil
+}
+
+func (r *PostgresAPIKeyRepository) ListAPIKeys(ctx context.Context) ([]*auth.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListAPIKeys: %w", err)
+	}
+	defer rows.Close()
+	keys := make([]*auth.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListAPIKeys: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListAPIKeys: %w", err)
+	}
+	return keys, nil
+}
+
+// RotateAPIKey swaps the secret of an unrevoked key.
+func (r *PostgresAPIKeyRepository) RotateAPIKey(ctx context.Context, id int64, prefix, hash string) (*auth.APIKey, error) {
+	row := r.db.QueryRowContext(ctx, `UPDATE api_keys SET prefix = $2, key_hash = $3, last_used_at = NULL
+	WHERE id = $1 AND revoked_at IS NULL RETURNING `+apiKeyColumns, id, prefix, hash)
+	k, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, auth.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("RotateAPIKey: %w", err)
+	}
+	return k, nil
+}
+
+func (r *PostgresAPIKeyRepository) RevokeAPIKey(ctx context.Context, id int64, at time.Time) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL`, id, at)
+	if err != nil {
+		return fmt.Errorf("RevokeAPIKey: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("RevokeAPIKey: %w", err)
+	}
+	if n == 0 {
+		return auth.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *PostgresAPIKeyRepository) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return fmt.Errorf("TouchAPIKey: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"homework/internal/models"
+)
+
+// maxBindParams keeps multi-row statements under the 65535 bind parameter
+// limit of the Postgres protocol.
+const maxBindParams = 60000
+
+// CreateBatch inserts orders in one transaction using multi-row INSERTs.
+// Orders whose ID is already taken, in the table or earlier in the batch, are
+// skipped and their IDs returned. With atomic set, any such conflict rolls
+// back the whole batch and is reported as ErrOrderExists.
+func (r *OrderRepository) CreateBatch(ctx context.Context, orders []*models.Order, atomic bool) ([]string, error) {
+	for _, o := range orders {
+		if err := r.applyPackaging(o); err != nil {
+			return nil, err
+		}
+		o.Version = 1
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	pending, err := pendingOrders(ctx, tx, orders)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureRecipients(ctx, tx, pending...); err != nil {
+		return nil, err
+	}
+	inserted, err := insertOrders(ctx, tx, orders)
+	if err != nil {
+		return nil, err
+	}
+	created, existing := splitInserted(orders, inserted)
+	if atomic && len(existing) > 0 {
+		return existing, fmt.Errorf("create batch: %d orders: %w", len(existing), ErrOrderExists)
+	}
+	if err := insertPackagingBatch(ctx, tx, created); err != nil {
+		return nil, err
+	}
+	if err := recordCreatedEvents(ctx, tx, created); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// pendingOrders returns the orders of the batch that are going to be
+// inserted: the first with each ID, unless the ID is taken in the table. Only
+// their recipients are ensured, so skipped duplicates leave none behind.
+func pendingOrders(ctx context.Context, tx *sql.Tx, orders []*models.Order) ([]*models.Order, error) {
+	ids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.ID)
+	}
+	taken := make(map[string]bool)
+	if err := collectIDs(ctx, tx, `SELECT id FROM orders WHERE id = ANY($1)`, []any{pq.Array(ids)}, taken); err != nil {
+		return nil, fmt.Errorf("create batch: %w", err)
+	}
+	pending := make([]*models.Order, 0, len(orders))
+	for _, o := range orders {
+		if !taken[o.ID] {
+			taken[o.ID] = true
+			pending = append(pending, o)
+		}
+	}
+	return pending, nil
+}
+
+// insertOrders inserts the orders chunk by chunk and returns the IDs that
+// were actually written.
+func insertOrders(ctx context.Context, tx *sql.Tx, orders []*models.Order) (map[string]bool, error) {
+	rows := make([][]any, 0, len(orders))
+	for _, o := range orders {
+		rows = append(rows, []any{
+			o.ID, o.RecipientID, o.StorageDeadline,
+			nullTime(o.AcceptedAt), nullTime(o.DeliveredAt), nullTime(o.ReturnedAt), nullTime(o.ClientReturnAt),
+			o.LastStateChange, o.Weight, o.Cost, o.FinalCost, o.Version, nullString(o.PickupPointID),
+		})
+	}
+	inserted := make(map[string]bool, len(orders))
+	prefix := `INSERT INTO orders (` + orderColumns + `) VALUES `
+	for _, chunk := range chunkRows(rows) {
+		query := prefix + placeholders(len(chunk), len(chunk[0])) + ` ON CONFLICT (id) DO NOTHING RETURNING id`
+		if err := collectIDs(ctx, tx, query, flatten(chunk), inserted); err != nil {
+			return nil, fmt.Errorf("create batch: %w", err)
+		}
+	}
+	return inserted, nil
+}
+
+func collectIDs(ctx context.Context, tx *sql.Tx, query string, args []any, ids map[string]bool) error {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids[id] = true
+	}
+	return rows.Err()
+}
+
+// splitInserted separates the orders that were written from the ones that
+// hit an existing ID. Only the first order with a given ID counts as written.
+func splitInserted(orders []*models.Order, inserted map[string]bool) ([]*models.Order, []string) {
+	var (
+		created  []*models.Order
+		existing []string
+	)
+	for _, o := range orders {
+		if inserted[o.ID] {
+			created = append(created, o)
+			delete(inserted, o.ID)
+			continue
+		}
+		existing = append(existing, o.ID)
+	}
+	return created, existing
+}
+
+func insertPackagingBatch(ctx context.Context, tx *sql.Tx, orders []*models.Order) error {
+	var rows [][]any
+	for _, o := range orders {
+		for _, pkg := range o.Packaging {
+			rows = append(rows, []any{o.ID, pkg})
+		}
+	}
+	return execValues(ctx, tx, `INSERT INTO order_packaging(order_id, pkg_value) VALUES `, rows)
+}
+
+func recordCreatedEvents(ctx context.Context, tx *sql.Tx, orders []*models.Order) error {
+	rows := make([][]any, 0, len(orders))
+	for _, o := range orders {
+		values, err := eventValues(ctx, models.OrderEventCreated, nil, o)
+		if err != nil {
+			return fmt.Errorf("recordEvent: %w", err)
+		}
+		rows = append(rows, values)
+	}
+	return execValues(ctx, tx,
+		`INSERT INTO order_events (order_id, event_type, old_state, new_state, changes, actor, created_at) VALUES `, rows)
+}
+
+// execValues runs prefix followed by a VALUES list for rows, split into as
+// many statements as the bind parameter limit requires.
+func execValues(ctx context.Context, tx *sql.Tx, prefix string, rows [][]any) error {
+	for _, chunk := range chunkRows(rows) {
+		query := prefix + placeholders(len(chunk), len(chunk[0]))
+		if _, err := tx.ExecContext(ctx, query, flatten(chunk)...); err != nil {
+			return fmt.Errorf("create batch: %w", err)
+		}
+	}
+	return nil
+}
+
+func chunkRows(rows [][]any) [][][]any {
+	if len(rows) == 0 {
+		return nil
+	}
+	size := max(1, maxBindParams/len(rows[0]))
+	var chunks [][][]any
+	for start := 0; start < len(rows); start += size {
+		chunks = append(chunks, rows[start:min(start+size, len(rows))])
+	}
+	return chunks
+}
+
+// placeholders renders "($1,$2),($3,$4)" for rows of cols values each.
+func placeholders(rows, cols int) string {
+	var b strings.Builder
+	n := 1
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('(')
+		for j := 0; j < cols; j++ {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			n++
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+func flatten(rows [][]any) []any {
+	var args []any
+	for _, row := range rows {
+		args = append(args, row...)
+	}
+	return args
+}
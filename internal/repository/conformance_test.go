@@ -0,0 +1,57 @@
+package repository_test
+
+import (
+	"testing"
+
+	"homework/internal/repository"
+	"homework/internal/repository/repotest"
+)
+
+func TestOrderRepositoryConformance(t *testing.T) {
+	repotest.RunOrderRepository(t, func(t *testing.T) repository.Repository {
+		cleanOrders(t)
+		return repo
+	})
+}
+
+func TestTaskRepositoryConformance(t *testing.T) {
+	repotest.RunTaskRepository(t, func(t *testing.T) repository.TaskRepository {
+		if _, err := db.Exec("DELETE FROM tasks"); err != nil {
+			t.Fatalf("clean tasks: %v", err)
+		}
+		return repository.NewPostgresTaskRepository(db)
+	})
+}
+
+func TestRecipientRepositoryConformance(t *testing.T) {
+	repotest.RunRecipientRepository(t, func(t *testing.T) repository.RecipientRepository {
+		cleanOrders(t)
+		if _, err := db.Exec("DELETE FROM recipients"); err != nil {
+			t.Fatalf("clean recipients: %v", err)
+		}
+		return repository.NewPostgresRecipientRepository(db)
+	})
+}
+
+func TestPickupPointRepositoryConformance(t *testing.T) {
+	repotest.RunPickupPointRepository(t, func(t *testing.T) (repository.Repository, repository.PickupPointRepository) {
+		cleanOrders(t)
+		if _, err := db.Exec("DELETE FROM pickup_points"); err != nil {
+			t.Fatalf("clean pickup_points: %v", err)
+		}
+		return repo, repository.NewPostgresPickupPointRepository(db)
+	})
+}
+
+func cleanOrders(t *testing.T) {
+	t.Helper()
+	if _, err := db.Exec("DELETE FROM order_packaging"); err != nil {
+		t.Fatalf("clean order_packaging: %v", err)
+	}
+	if _, err := db.Exec("DELETE FROM orders"); err != nil {
+		t.Fatalf("clean orders: %v", err)
+	}
+	if _, err := db.Exec("DELETE FROM order_events"); err != nil {
+		t.Fatalf("clean order_events: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"homework/internal/auth"
+	"homework/internal/models"
+)
+
+// recordEvent appends an entry to the order history inside tx, so that it is
+// committed or rolled back together with the change it describes.
+func recordEvent(ctx context.Context, tx *sql.Tx, eventType models.OrderEventType, before, after *models.Order) error {
+	values, err := eventValues(ctx, eventType, before, after)
+	if err != nil {
+		return fmt.Errorf("recordEvent: %w", err)
+	}
+	query := `INSERT INTO order_events (order_id, event_type, old_state, new_state, changes, actor, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("recordEvent: %w", err)
+	}
+	return nil
+}
+
+// eventValues returns the order_events column values of one event, in the
+// order used by recordEvent.
+func eventValues(ctx context.Context, eventType models.OrderEventType, before, after *models.Order) ([]any, error) {
+	e := models.NewOrderEvent(eventType, before, after, auth.ActorFromContext(ctx))
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return nil, err
+	}
+	if e.Changes == nil {
+		changes = []byte("[]")
+	}
+	return []any{e.OrderID, e.Type, e.OldState, e.NewState, changes, e.Actor, e.CreatedAt}, nil
+}
+
+// History returns every recorded event of the order, oldest first.
+func (r *OrderRepository) History(ctx context.Context, orderID string) ([]models.OrderEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, event_type, old_state, new_state, changes, actor, created_at
+	FROM order_events WHERE order_id = $1 ORDER BY id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("History: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.OrderEvent
+	for rows.Next() {
+		var (
+			e       models.OrderEvent
+			changes []byte
+		)
+		if err := rows.Scan(&e.ID, &e.OrderID, &e.Type, &e.OldState, &e.NewState, &changes, &e.Actor, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(changes, &e.Changes); err != nil {
+			return nil, fmt.Errorf("History: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		return events, nil
+	}
+
+	o, err := r.GetID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return nil, fmt.Errorf("order %s: %w", orderID, ErrOrderNotFound)
+	}
+	return []models.OrderEvent{}, nil
+}
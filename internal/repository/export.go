@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"homework/internal/models"
+)
+
+// Export streams the orders matching f, ordered by id, to fn one at a time,
+// so memory use does not depend on the number of rows. Packaging is
+// aggregated in the same query. Iteration stops at the first error of fn.
+func (r *OrderRepository) Export(ctx context.Context, f OrderFilter, fn func(*models.Order) error) error {
+	var b queryBuilder
+	f.apply(&b)
+	query := `SELECT ` + orderColumns + `,
+		(SELECT array_agg(pkg_value) FROM order_packaging WHERE order_id = orders.id)
+	FROM orders` + b.whereClause() + ` ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return fmt.Errorf("export orders: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pkgs pq.StringArray
+		o, err := scanOrder(rows, &pkgs)
+		if err != nil {
+			return fmt.Errorf("export orders: %w", err)
+		}
+		o.Packaging = []string(pkgs)
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("export orders: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"homework/internal/models"
+)
+
+// stateExpr computes the state of an order row the same way as
+// models.Order.CurrentState.
+const stateExpr = `CASE
+		WHEN returned_at IS NOT NULL THEN 'returned'
+		WHEN client_return_at IS NOT NULL THEN 'client_rtn'
+		WHEN delivered_at IS NOT NULL THEN 'delivered'
+		WHEN accepted_at IS NOT NULL THEN 'accepted'
+		ELSE '' END`
+
+// TimeRange is the half-open interval [From, To). A zero bound is open.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// FloatRange is the closed interval [Min, Max]. A zero bound is open, which
+// loses nothing for weights and costs since they are always positive.
+type FloatRange struct {
+	Min float64
+	Max float64
+}
+
+// OrderFilter selects orders. Zero fields do not filter.
+type OrderFilter struct {
+	// States matches any of the listed states; models.OrderStateNew is the
+	// empty string.
+	States      []models.OrderState
+	RecipientID string
+	// StateChanged bounds last_state_change.
+	StateChanged TimeRange
+	Deadline     TimeRange
+	Accepted     TimeRange
+	Delivered    TimeRange
+	Weight       FloatRange
+	Cost         FloatRange
+	// Packaging matches orders that use the packaging type, alone or
+	// combined with others.
+	Packaging     string
+	PickupPointID string
+}
+
+// Match reports whether o passes the filter. It mirrors the SQL built by
+// apply for repositories that filter in memory.
+func (f OrderFilter) Match(o *models.Order) bool {
+	checks := []bool{
+		len(f.States) == 0 || slices.Contains(f.States, o.CurrentState()),
+		f.RecipientID == "" || o.RecipientID == f.RecipientID,
+		f.StateChanged.Contains(o.LastStateChange),
+		f.Deadline.Contains(o.StorageDeadline),
+		f.Accepted.Contains(o.AcceptedAt),
+		f.Delivered.Contains(o.DeliveredAt),
+		f.Weight.Contains(o.Weight),
+		f.Cost.Contains(o.Cost),
+		f.Packaging == "" || slices.Contains(o.Packaging.Names(), f.Packaging),
+		f.PickupPointID == "" || o.PickupPointID == f.PickupPointID,
+	}
+	return !slices.Contains(checks, false)
+}
+
+// Contains reports whether t lies in the range. A zero t, such as a missing
+// timestamp, only lies in an unbounded range.
+func (r TimeRange) Contains(t time.Time) bool {
+	if r.From.IsZero() && r.To.IsZero() {
+		return true
+	}
+	if t.IsZero() {
+		return false
+	}
+	return (r.From.IsZero() || !t.Before(r.From)) && (r.To.IsZero() || t.Before(r.To))
+}
+
+func (r FloatRange) Contains(v float64) bool {
+	return (r.Min == 0 || v >= r.Min) && (r.Max == 0 || v <= r.Max)
+}
+
+func (f OrderFilter) apply(b *queryBuilder) {
+	if len(f.States) > 0 {
+		states := make([]string, 0, len(f.States))
+		for _, s := range f.States {
+			states = append(states, string(s))
+		}
+		b.where("("+stateExpr+") = ANY(?)", pq.Array(states))
+	}
+	if f.RecipientID != "" {
+		b.where("recipient_id = ?", f.RecipientID)
+	}
+	if f.PickupPointID != "" {
+		b.where("pickup_point_id = ?", f.PickupPointID)
+	}
+	b.between("last_state_change", f.StateChanged)
+	b.between("storage_deadline", f.Deadline)
+	b.between("accepted_at", f.Accepted)
+	b.between("delivered_at", f.Delivered)
+	b.within("weight", f.Weight)
+	b.within("cost", f.Cost)
+	if f.Packaging != "" {
+		b.where("EXISTS (SELECT 1 FROM order_packaging WHERE order_id = orders.id AND pkg_value = ?)", f.Packaging)
+	}
+}
+
+// queryBuilder collects WHERE conditions written with "?" placeholders and
+// numbers them as Postgres parameters.
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (b *queryBuilder) where(cond string, args ...any) {
+	var sb strings.Builder
+	parts := strings.Split(cond, "?")
+	for i, part := range parts {
+		sb.WriteString(part)
+		if i < len(parts)-1 {
+			b.args = append(b.args, args[i])
+			sb.WriteString("$" + strconv.Itoa(len(b.args)))
+		}
+	}
+	b.conditions = append(b.conditions, sb.String())
+}
+
+func (b *queryBuilder) between(column string, r TimeRange) {
+	if !r.From.IsZero() {
+		b.where(column+" >= ?", r.From)
+	}
+	if !r.To.IsZero() {
+		b.where(column+" < ?", r.To)
+	}
+}
+
+func (b *queryBuilder) within(column string, r FloatRange) {
+	if r.Min != 0 {
+		b.where(column+" >= ?", r.Min)
+	}
+	if r.Max != 0 {
+		b.where(column+" <= ?", r.Max)
+	}
+}
+
+// arg adds a parameter that is not part of a condition, such as a LIMIT,
+// and returns its placeholder.
+func (b *queryBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *queryBuilder) whereClause() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"homework/internal/idempotency"
+)
+
+var _ idempotency.Store = (*PostgresIdempotencyRepository)(nil)
+
+type PostgresIdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresIdempotencyRepository(db *sql.DB) *PostgresIdempotencyRepository {
+	return &PostgresIdempotencyRepository{db: db}
+}
+
+// Reserve inserts the record, taking over a row whose TTL has passed. When
+// the key is held by a live record, that record is returned instead.
+func (r *PostgresIdempotencyRepository) Reserve(ctx context.Context, rec *idempotency.Record) (*idempotency.Record, bool, error) {
+	query := `INSERT INTO idempotency_keys (principal, key, request_hash, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (principal, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, completed = FALSE, status_code = 0,
+			content_type = '', body = NULL, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < EXCLUDED.created_at`
+	res, err := r.db.ExecContext(ctx, query, rec.Principal, rec.Key, rec.RequestHash, rec.CreatedAt, rec.ExpiresAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("Reserve: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("Reserve: %w", err)
+	}
+	if n == 1 {
+		return nil, true, nil
+	}
+	existing, err := r.get(ctx, rec.Principal, rec.Key)
+	if err != nil {
+		return nil, false, fmt.Errorf("Reserve: %w", err)
+	}
+	return existing, false, nil
+}
+
+func (r *PostgresIdempotencyRepository) get(ctx context.Context, principal, key string) (*idempotency.Record, error) {
+	rec := idempotency.Record{Principal: principal, Key: key}
+	err := r.db.QueryRowContext(ctx, `SELECT request_hash, completed, status_code, content_type, body, created_at, expires_at
+	FROM idempotency_keys WHERE principal = $1 AND key = $2`, principal, key).Scan(
+		&rec.RequestHash, &rec.Completed, &rec.StatusCode, &rec.ContentType, &rec.Body, &rec.CreatedAt, &rec.ExpiresAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, idempotency.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (r *PostgresIdempotencyRepository) Complete(ctx context.Context, principal, key string, statusCode int, contentType string, body []byte) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE idempotency_keys
+	SET completed = TRUE, status_code = $3, content_type = $4, body = $5
+	WHERE principal = $1 AND key = $2`, principal, key, statusCode, contentType, body)
+	if err != nil {
+		return fmt.Errorf("Complete: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresIdempotencyRepository) Release(ctx context.Context, principal, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys
+	WHERE principal = $1 AND key = $2 AND NOT completed`, principal, key)
+	if err != nil {
+		return fmt.Errorf("Release: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresIdempotencyRepository) Purge(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("Purge: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"homework/internal/auth"
+)
+
+var _ auth.APIKeyStore = (*APIKeyRepository)(nil)
+
+type APIKeyRepository struct {
+	mu     sync.Mutex
+	nextID int64
+	keys   map[int64]*auth.APIKey
+}
+
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{keys: make(map[int64]*auth.APIKey)}
+}
+
+func cloneAPIKey(k *auth.APIKey) *auth.APIKey {
+	c := *k
+	c.Scopes = append([]auth.Permission(nil), k.Scopes...)
+	return &c
+}
+
+func (r *APIKeyRepository) CreateAPIKey(_ context.Context, k *auth.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	k.ID = r.nextID
+	r.keys[k.ID] = cloneAPIKey(k)
+	return nil
+}
+
+func (r *APIKeyRepository) GetAPIKeyByPrefix(_ context.Context, prefix string) (*auth.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.keys {
+		if k.Prefix == prefix {
+			return cloneAPIKey(k), nil
+		}
+	}
+	return nil, auth.ErrAPIKeyNotFound
+}
+
+func (r *APIKeyRepository) ListAPIKeys(_ context.Context) ([]*auth.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]*auth.APIKey, 0, len(r.keys))
+	for _, k := range r.keys {
+		keys = append(keys, cloneAPIKey(k))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (r *APIKeyRepository) RotateAPIKey(_ context.Context, id int64, prefix, hash string) (*auth.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[id]
+	if !ok || !k.RevokedAt.IsZero() {
+		return nil, auth.ErrAPIKeyNotFound
+	}
+	k.Prefix, k.Hash, k.LastUsedAt = prefix, hash, time.Time{}
+	return cloneAPIKey(k), nil
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(_ context.Context, id int64, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[id]
+	if !ok || !k.RevokedAt.IsZero() {
+		return auth.ErrAPIKeyNotFound
+	}
+	k.RevokedAt = at
+	return nil
+}
+
+func (r *APIKeyRepository) TouchAPIKey(_ context.Context, id int64, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if k, ok := r.keys[id]; ok {
+		k.LastUsedAt = at
+	}
+	return nil
+}
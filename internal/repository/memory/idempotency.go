@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"homework/internal/idempotency"
+)
+
+var _ idempotency.Store = (*IdempotencyRepository)(nil)
+
+type idempotencyKey struct{ principal, key string }
+
+type IdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[idempotencyKey]*idempotency.Record
+}
+
+func NewIdempotencyRepository() *IdempotencyRepository {
+	return &IdempotencyRepository{records: make(map[idempotencyKey]*idempotency.Record)}
+}
+
+func cloneRecord(rec *idempotency.Record) *idempotency.Record {
+	c := *rec
+	c.Body = append([]byte(nil), rec.Body...)
+	return &c
+}
+
+func (r *IdempotencyRepository) Reserve(_ context.Context, rec *idempotency.Record) (*idempotency.Record, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := idempotencyKey{rec.Principal, rec.Key}
+	if existing, ok := r.records[k]; ok && !existing.ExpiresAt.Before(rec.CreatedAt) {
+		return cloneRecord(existing), false, nil
+	}
+	stored := cloneRecord(rec)
+	stored.Completed = false
+	r.records[k] = stored
+	return nil, true, nil
+}
+
+func (r *IdempotencyRepository) Complete(_ context.Context, principal, key string, statusCode int, contentType string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, ok := r.records[idempotencyKey{principal, key}]
+	if !ok {
+		return idempotency.ErrNotFound
+	}
+	rec.Completed = true
+	rec.StatusCode = statusCode
+	rec.ContentType = contentType
+	rec.Body = append([]byte(nil), body...)
+	return nil
+}
+
+func (r *IdempotencyRepository) Release(_ context.Context, principal, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := idempotencyKey{principal, key}
+	if rec, ok := r.records[k]; ok && !rec.Completed {
+		delete(r.records, k)
+	}
+	return nil
+}
+
+func (r *IdempotencyRepository) Purge(_ context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for k, rec := range r.records {
+		if rec.ExpiresAt.Before(now) {
+			delete(r.records, k)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,34 @@
+package memory_test
+
+import (
+	"testing"
+
+	"homework/internal/repository"
+	"homework/internal/repository/memory"
+	"homework/internal/repository/repotest"
+)
+
+func TestOrderRepository(t *testing.T) {
+	repotest.RunOrderRepository(t, func(*testing.T) repository.Repository {
+		return memory.NewOrderRepository()
+	})
+}
+
+func TestTaskRepository(t *testing.T) {
+	repotest.RunTaskRepository(t, func(*testing.T) repository.TaskRepository {
+		return memory.NewTaskRepository()
+	})
+}
+
+func TestRecipientRepository(t *testing.T) {
+	repotest.RunRecipientRepository(t, func(*testing.T) repository.RecipientRepository {
+		return memory.NewRecipientRepository()
+	})
+}
+
+func TestPickupPointRepository(t *testing.T) {
+	repotest.RunPickupPointRepository(t, func(*testing.T) (repository.Repository, repository.PickupPointRepository) {
+		repo := memory.NewOrderRepository()
+		return repo, repo
+	})
+}
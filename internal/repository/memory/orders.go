@@ -0,0 +1,396 @@
+// Package memory provides in-memory implementations of the repository
+// interfaces. They follow the semantics of the Postgres repositories and are
+// meant for tests of the layers above the database.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"homework/internal/auth"
+	"homework/internal/models"
+	"homework/internal/packaging"
+	"homework/internal/repository"
+)
+
+var _ repository.Repository = (*OrderRepository)(nil)
+
+type OrderRepository struct {
+	mu        sync.RWMutex
+	orders    map[string]*models.Order
+	events    []models.OrderEvent
+	points    map[string]*models.PickupPoint
+	packaging *packaging.Registry
+	// recipients, if set, gets the recipients of written orders the way the
+	// Postgres repository adds them in the order's transaction.
+	recipients *RecipientRepository
+}
+
+func NewOrderRepository() *OrderRepository {
+	return &OrderRepository{
+		orders:    make(map[string]*models.Order),
+		points:    make(map[string]*models.PickupPoint),
+		packaging: packaging.Default,
+	}
+}
+
+// WithRecipients makes r create the missing recipients of the orders it
+// writes in recipients, and returns r.
+func (r *OrderRepository) WithRecipients(recipients *RecipientRepository) *OrderRepository {
+	r.recipients = recipients
+	return r
+}
+
+// ensureRecipients creates the missing recipients of orders. The caller
+// holds r.mu.
+func (r *OrderRepository) ensureRecipients(ctx context.Context, orders ...*models.Order) {
+	if r.recipients == nil {
+		return
+	}
+	for _, o := range orders {
+		_, _ = r.recipients.EnsureRecipient(ctx, o.RecipientID)
+	}
+}
+
+func (r *OrderRepository) Create(ctx context.Context, o *models.Order) error {
+	if err := r.applyPackaging(o); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.orders[o.ID]; ok {
+		return fmt.Errorf("order %s: %w", o.ID, repository.ErrOrderExists)
+	}
+	if err := r.checkPickupPoint(o); err != nil {
+		return err
+	}
+	r.ensureRecipients(ctx, o)
+	o.Version = 1
+	r.orders[o.ID] = clone(o)
+	r.recordEvent(ctx, models.OrderEventCreated, nil, o)
+	return nil
+}
+
+func (r *OrderRepository) CreateBatch(ctx context.Context, orders []*models.Order, atomic bool) ([]string, error) {
+	for _, o := range orders {
+		if err := r.applyPackaging(o); err != nil {
+			return nil, err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var (
+		created  []*models.Order
+		existing []string
+		seen     = make(map[string]bool, len(orders))
+	)
+	for _, o := range orders {
+		if err := r.checkPickupPoint(o); err != nil {
+			return nil, err
+		}
+	}
+	for _, o := range orders {
+		if _, ok := r.orders[o.ID]; ok || seen[o.ID] {
+			existing = append(existing, o.ID)
+			continue
+		}
+		seen[o.ID] = true
+		created = append(created, o)
+	}
+	if atomic && len(existing) > 0 {
+		return existing, fmt.Errorf("create batch: %d orders: %w", len(existing), repository.ErrOrderExists)
+	}
+	r.ensureRecipients(ctx, created...)
+	for _, o := range created {
+		o.Version = 1
+		r.orders[o.ID] = clone(o)
+		r.recordEvent(ctx, models.OrderEventCreated, nil, o)
+	}
+	return existing, nil
+}
+
+func (r *OrderRepository) List(ctx context.Context, cursor string, limit int64, recipientID string) ([]*models.Order, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sorted(limit, 0, func(o *models.Order) bool {
+		return o.ID > cursor && (recipientID == "" || o.RecipientID == recipientID)
+	}), nil
+}
+
+// GetByID ignores tx: every call already sees the latest committed state.
+func (r *OrderRepository) GetByID(ctx context.Context, _ *sql.Tx, id string) (*models.Order, error) {
+	return r.GetID(ctx, id)
+}
+
+func (r *OrderRepository) Search(ctx context.Context, q repository.OrderQuery) ([]*models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var matched []*models.Order
+	for _, o := range r.orders {
+		if q.Filter.Match(o) && (q.After == nil || q.Sort.After(o, *q.After)) {
+			matched = append(matched, o)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return q.Sort.Compare(matched[i], matched[j]) < 0 })
+	result := make([]*models.Order, 0, min(len(matched), q.Limit))
+	for _, o := range matched[:min(len(matched), q.Limit)] {
+		result = append(result, clone(o))
+	}
+	return result, nil
+}
+
+// Export hands fn copies of the matching orders. They are collected under
+// the lock first, so fn may call back into the repository.
+func (r *OrderRepository) Export(ctx context.Context, f repository.OrderFilter, fn func(*models.Order) error) error {
+	r.mu.RLock()
+	orders := r.sorted(math.MaxInt64, 0, f.Match)
+	r.mu.RUnlock()
+	for _, o := range orders {
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *OrderRepository) GetID(ctx context.Context, id string) (*models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	o, ok := r.orders[id]
+	if !ok {
+		return nil, nil
+	}
+	return clone(o), nil
+}
+
+func (r *OrderRepository) Update(ctx context.Context, _ *sql.Tx, o *models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.update(o)
+}
+
+func (r *OrderRepository) UpdateTx(ctx context.Context, o *models.Order) error {
+	return r.updateTx(ctx, o, 0)
+}
+
+func (r *OrderRepository) UpdateIfVersion(ctx context.Context, o *models.Order, version int64) error {
+	if version <= 0 {
+		return fmt.Errorf("order %s: version %d: %w", o.ID, version, repository.ErrVersionMismatch)
+	}
+	return r.updateTx(ctx, o, version)
+}
+
+func (r *OrderRepository) updateTx(ctx context.Context, o *models.Order, version int64) error {
+	if err := r.applyPackaging(o); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current := r.orders[o.ID]
+	if err := repository.CheckUpdate(current, o, version); err != nil {
+		return err
+	}
+	if err := r.claimShelf(current, o); err != nil {
+		return err
+	}
+	if err := r.update(o); err != nil {
+		return err
+	}
+	if o.RecipientID != current.RecipientID {
+		r.ensureRecipients(ctx, o)
+	}
+	r.recordEvent(ctx, models.OrderEventUpdated, current, o)
+	return nil
+}
+
+// update stores o and bumps its version. The caller holds r.mu.
+func (r *OrderRepository) update(o *models.Order) error {
+	current, ok := r.orders[o.ID]
+	if !ok {
+		return fmt.Errorf("order %s: %w", o.ID, repository.ErrOrderNotFound)
+	}
+	if err := r.checkPickupPoint(o); err != nil {
+		return err
+	}
+	o.Version = current.Version + 1
+	r.orders[o.ID] = clone(o)
+	return nil
+}
+
+func (r *OrderRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.orders[id]
+	if !ok {
+		return fmt.Errorf("order %s: %w", id, repository.ErrOrderNotFound)
+	}
+	delete(r.orders, id)
+	r.recordEvent(ctx, models.OrderEventDeleted, o, nil)
+	return nil
+}
+
+func (r *OrderRepository) Deliver(ctx context.Context, id string) error {
+	return r.transition(ctx, id, models.OrderStateDelivered)
+}
+
+func (r *OrderRepository) ClientReturn(ctx context.Context, id string) error {
+	return r.transition(ctx, id, models.OrderStateClientRtn)
+}
+
+func (r *OrderRepository) AcceptOrder(ctx context.Context, id string) error {
+	return r.transition(ctx, id, models.OrderStateAccepted)
+}
+
+func (r *OrderRepository) ReturnOrder(ctx context.Context, id string) error {
+	return r.transition(ctx, id, models.OrderStateReturned)
+}
+
+func (r *OrderRepository) transition(ctx context.Context, id string, state models.OrderState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.orders[id]
+	if !ok {
+		return fmt.Errorf("order %s: %w", id, repository.ErrOrderNotFound)
+	}
+	o := clone(stored)
+	if err := o.UpdateState(state); err != nil {
+		return fmt.Errorf("order %s: %w", id, err)
+	}
+	if err := r.claimShelf(stored, o); err != nil {
+		return err
+	}
+	o.Version++
+	r.orders[id] = o
+	r.recordEvent(ctx, models.OrderEventStateChanged, stored, o)
+	return nil
+}
+
+func (r *OrderRepository) ExpireOverdue(ctx context.Context, now time.Time, limit int) ([]*models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var overdue []*models.Order
+	for _, o := range r.orders {
+		if o.CurrentState() == models.OrderStateAccepted && o.StorageDeadline.Before(now) {
+			overdue = append(overdue, o)
+		}
+	}
+	sort.Slice(overdue, func(i, j int) bool {
+		if overdue[i].StorageDeadline.Equal(overdue[j].StorageDeadline) {
+			return overdue[i].ID < overdue[j].ID
+		}
+		return overdue[i].StorageDeadline.Before(overdue[j].StorageDeadline)
+	})
+	if len(overdue) > limit {
+		overdue = overdue[:limit]
+	}
+
+	expired := make([]*models.Order, 0, len(overdue))
+	for _, stored := range overdue {
+		o := clone(stored)
+		if err := o.UpdateState(models.OrderStateReturned); err != nil {
+			return nil, fmt.Errorf("order %s: %w", o.ID, err)
+		}
+		o.Version++
+		r.orders[o.ID] = o
+		r.recordEvent(ctx, models.OrderEventStateChanged, stored, o)
+		expired = append(expired, clone(o))
+	}
+	return expired, nil
+}
+
+func (r *OrderRepository) GetReturns(ctx context.Context, q repository.ReturnsQuery) ([]*models.Order, error) {
+	if q.Limit <= 0 {
+		q.Limit = 10
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var matched []*models.Order
+	for _, o := range r.orders {
+		if q.Match(o) {
+			matched = append(matched, o)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return repository.CompareReturns(matched[i], matched[j]) < 0 })
+	matched = matched[min(q.Offset, int64(len(matched))):]
+	result := make([]*models.Order, 0, min(int64(len(matched)), q.Limit))
+	for _, o := range matched[:cap(result)] {
+		result = append(result, clone(o))
+	}
+	return result, nil
+}
+
+func (r *OrderRepository) FetchPackaging(ctx context.Context, orderID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	o, ok := r.orders[orderID]
+	if !ok || len(o.Packaging) == 0 {
+		return nil, nil
+	}
+	return append([]string(nil), o.Packaging...), nil
+}
+
+func (r *OrderRepository) History(ctx context.Context, orderID string) ([]models.OrderEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	events := []models.OrderEvent{}
+	for _, e := range r.events {
+		if e.OrderID == orderID {
+			events = append(events, e)
+		}
+	}
+	if _, ok := r.orders[orderID]; !ok && len(events) == 0 {
+		return nil, fmt.Errorf("order %s: %w", orderID, repository.ErrOrderNotFound)
+	}
+	return events, nil
+}
+
+// recordEvent appends to the order history. The caller holds r.mu.
+func (r *OrderRepository) recordEvent(ctx context.Context, eventType models.OrderEventType, before, after *models.Order) {
+	e := models.NewOrderEvent(eventType, before, after, auth.ActorFromContext(ctx))
+	e.ID = int64(len(r.events) + 1)
+	r.events = append(r.events, e)
+}
+
+func (r *OrderRepository) applyPackaging(o *models.Order) error {
+	finalCost, err := r.packaging.FinalCost(o.Packaging, o.Weight, o.Cost)
+	if err != nil {
+		return fmt.Errorf("order %s: %w", o.ID, err)
+	}
+	o.Packaging = o.Packaging.Names()
+	o.FinalCost = finalCost
+	return nil
+}
+
+// sorted returns copies of the orders matching keep, ordered by id, with
+// offset and limit applied. The caller holds r.mu.
+func (r *OrderRepository) sorted(limit, offset int64, keep func(*models.Order) bool) []*models.Order {
+	var matched []*models.Order
+	for _, o := range r.orders {
+		if keep(o) {
+			matched = append(matched, o)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+
+	var result []*models.Order
+	for i := offset; i < int64(len(matched)) && int64(len(result)) < limit; i++ {
+		result = append(result, clone(matched[i]))
+	}
+	return result
+}
+
+func clone(o *models.Order) *models.Order {
+	c := *o
+	if o.Packaging != nil {
+		c.Packaging = append(packaging.Packaging(nil), o.Packaging...)
+	}
+	return &c
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+// Pickup points are kept by OrderRepository rather than a store of their
+// own: accepting an order checks the capacity of its point under the lock
+// that guards the orders, as Postgres does within one transaction.
+var _ repository.PickupPointRepository = (*OrderRepository)(nil)
+
+func (r *OrderRepository) CreatePickupPoint(_ context.Context, p *models.PickupPoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.points[p.ID]; ok {
+		return fmt.Errorf("pickup point %s: %w", p.ID, repository.ErrPickupPointExists)
+	}
+	p.CreatedAt = time.Now().UTC()
+	c := *p
+	r.points[p.ID] = &c
+	return nil
+}
+
+func (r *OrderRepository) GetPickupPoint(_ context.Context, id string) (*models.PickupPoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.points[id]
+	if !ok {
+		return nil, fmt.Errorf("pickup point %s: %w", id, repository.ErrPickupPointNotFound)
+	}
+	return r.withOccupancy(p), nil
+}
+
+func (r *OrderRepository) ListPickupPoints(_ context.Context) ([]*models.PickupPoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	points := make([]*models.PickupPoint, 0, len(r.points))
+	for _, p := range r.points {
+		points = append(points, r.withOccupancy(p))
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].ID < points[j].ID })
+	return points, nil
+}
+
+func (r *OrderRepository) UpdatePickupPoint(_ context.Context, p *models.PickupPoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.points[p.ID]
+	if !ok {
+		return fmt.Errorf("pickup point %s: %w", p.ID, repository.ErrPickupPointNotFound)
+	}
+	c := *p
+	c.CreatedAt = stored.CreatedAt
+	r.points[p.ID] = &c
+	*p = *r.withOccupancy(&c)
+	return nil
+}
+
+// withOccupancy returns a copy of p with Occupied filled in. The caller
+// holds r.mu.
+func (r *OrderRepository) withOccupancy(p *models.PickupPoint) *models.PickupPoint {
+	c := *p
+	c.Occupied = r.occupied(p.ID)
+	return &c
+}
+
+func (r *OrderRepository) occupied(pointID string) int {
+	n := 0
+	for _, o := range r.orders {
+		if o.PickupPointID == pointID && o.OnShelf() {
+			n++
+		}
+	}
+	return n
+}
+
+// claimShelf reserves a shelf place for next if it needs one, see
+// repository.TakesShelf. The caller holds r.mu.
+func (r *OrderRepository) claimShelf(prev, next *models.Order) error {
+	if !repository.TakesShelf(prev, next) {
+		return nil
+	}
+	return r.reserveShelf(next.PickupPointID)
+}
+
+// reserveShelf mirrors the Postgres capacity check. The caller holds r.mu.
+func (r *OrderRepository) reserveShelf(pointID string) error {
+	p, ok := r.points[pointID]
+	if !ok {
+		return fmt.Errorf("pickup point %s: %w", pointID, repository.ErrPickupPointNotFound)
+	}
+	if p.Capacity == 0 {
+		return nil
+	}
+	if occupied := r.occupied(pointID); occupied >= p.Capacity {
+		return fmt.Errorf("pickup point %s: %d of %d places taken: %w", pointID, occupied, p.Capacity, repository.ErrPickupPointFull)
+	}
+	return nil
+}
+
+// checkPickupPoint mirrors the foreign key from orders to pickup points.
+// The caller holds r.mu.
+func (r *OrderRepository) checkPickupPoint(o *models.Order) error {
+	if o.PickupPointID == "" {
+		return nil
+	}
+	if _, ok := r.points[o.PickupPointID]; !ok {
+		return fmt.Errorf("order %s: pickup point %s: %w", o.ID, o.PickupPointID, repository.ErrPickupPointNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+var _ repository.RecipientRepository = (*RecipientRepository)(nil)
+
+// RecipientRepository keeps recipients in memory. It has no view of the
+// orders, so DeleteRecipient never reports repository.ErrRecipientHasOrders.
+type RecipientRepository struct {
+	mu         sync.Mutex
+	recipients map[string]*models.Recipient
+}
+
+func NewRecipientRepository() *RecipientRepository {
+	return &RecipientRepository{recipients: make(map[string]*models.Recipient)}
+}
+
+func (r *RecipientRepository) CreateRecipient(_ context.Context, rc *models.Recipient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.recipients[rc.ID]; ok {
+		return fmt.Errorf("recipient %s: %w", rc.ID, repository.ErrRecipientExists)
+	}
+	rc.CreatedAt = time.Now().UTC()
+	rc.UpdatedAt = rc.CreatedAt
+	c := *rc
+	r.recipients[rc.ID] = &c
+	return nil
+}
+
+func (r *RecipientRepository) GetRecipient(_ context.Context, id string) (*models.Recipient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rc, ok := r.recipients[id]
+	if !ok {
+		return nil, fmt.Errorf("recipient %s: %w", id, repository.ErrRecipientNotFound)
+	}
+	c := *rc
+	return &c, nil
+}
+
+func (r *RecipientRepository) ListRecipients(_ context.Context, after string, limit int) ([]*models.Recipient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recipients := make([]*models.Recipient, 0)
+	for _, rc := range r.recipients {
+		if rc.ID > after {
+			c := *rc
+			recipients = append(recipients, &c)
+		}
+	}
+	sort.Slice(recipients, func(i, j int) bool { return recipients[i].ID < recipients[j].ID })
+	return recipients[:min(len(recipients), limit)], nil
+}
+
+func (r *RecipientRepository) UpdateRecipient(_ context.Context, rc *models.Recipient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.recipients[rc.ID]
+	if !ok {
+		return fmt.Errorf("recipient %s: %w", rc.ID, repository.ErrRecipientNotFound)
+	}
+	rc.CreatedAt = stored.CreatedAt
+	rc.UpdatedAt = time.Now().UTC()
+	c := *rc
+	r.recipients[rc.ID] = &c
+	return nil
+}
+
+func (r *RecipientRepository) DeleteRecipient(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.recipients[id]; !ok {
+		return fmt.Errorf("recipient %s: %w", id, repository.ErrRecipientNotFound)
+	}
+	delete(r.recipients, id)
+	return nil
+}
+
+func (r *RecipientRepository) EnsureRecipient(_ context.Context, id string) (*models.Recipient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rc, ok := r.recipients[id]
+	if !ok {
+		now := time.Now().UTC()
+		rc = &models.Recipient{ID: id, Status: models.RecipientActive, CreatedAt: now, UpdatedAt: now}
+		r.recipients[id] = rc
+	}
+	c := *rc
+	return &c, nil
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"homework/internal/repository"
+)
+
+var _ repository.TaskRepository = (*TaskRepository)(nil)
+
+type TaskRepository struct {
+	mu     sync.Mutex
+	nextID int
+	tasks  map[int]*repository.Task
+}
+
+func NewTaskRepository() *TaskRepository {
+	return &TaskRepository{tasks: make(map[int]*repository.Task)}
+}
+
+func (r *TaskRepository) CreateTask(_ context.Context, auditData []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	now := time.Now().UTC()
+	r.tasks[r.nextID] = &repository.Task{
+		ID:        r.nextID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		AuditData: append([]byte(nil), auditData...),
+		Status:    repository.TaskStatusCreated,
+	}
+	return nil
+}
+
+// GetPendingTasks claims due tasks under the repository lock, so concurrent
+// callers never receive the same task, like FOR UPDATE SKIP LOCKED does.
+func (r *TaskRepository) GetPendingTasks(_ context.Context, limit int, maxAttempts int, retryDelay time.Duration) ([]*repository.Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+
+	var due []*repository.Task
+	for _, t := range r.tasks {
+		if isDue(t, now, maxAttempts) {
+			due = append(due, t)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].CreatedAt.Equal(due[j].CreatedAt) {
+			return due[i].ID < due[j].ID
+		}
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*repository.Task, 0, len(due))
+	for _, t := range due {
+		t.Status = repository.TaskStatusProcessing
+		t.AttemptCount++
+		t.UpdatedAt = now
+		t.NextAttemptAt = sql.NullTime{Time: now.Add(retryDelay), Valid: true}
+		c := *t
+		claimed = append(claimed, &c)
+	}
+	return claimed, nil
+}
+
+func isDue(t *repository.Task, now time.Time, maxAttempts int) bool {
+	if t.Status != repository.TaskStatusCreated && t.Status != repository.TaskStatusFailed {
+		return false
+	}
+	if t.NextAttemptAt.Valid && t.NextAttemptAt.Time.After(now) {
+		return false
+	}
+	return t.AttemptCount < maxAttempts
+}
+
+func (r *TaskRepository) MarkTaskProcessing(_ context.Context, taskID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.tasks[taskID]; ok {
+		t.Status = repository.TaskStatusProcessing
+		t.UpdatedAt = time.Now().UTC()
+	}
+	return nil
+}
+
+func (r *TaskRepository) DeleteTask(_ context.Context, taskID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tasks, taskID)
+	return nil
+}
+
+func (r *TaskRepository) UpdateTaskFailure(_ context.Context, taskID int, attemptCount int, newStatus repository.TaskStatus, nextAttemptAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.tasks[taskID]; ok {
+		t.Status = newStatus
+		t.AttemptCount = attemptCount
+		t.UpdatedAt = time.Now().UTC()
+		t.NextAttemptAt = sql.NullTime{Time: nextAttemptAt, Valid: true}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"homework/internal/auth"
+)
+
+var _ auth.Denylist = (*RevokedTokenRepository)(nil)
+
+type RevokedTokenRepository struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewRevokedTokenRepository() *RevokedTokenRepository {
+	return &RevokedTokenRepository{revoked: make(map[string]time.Time)}
+}
+
+func (r *RevokedTokenRepository) Revoke(_ context.Context, jti string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, exp := range r.revoked {
+		if exp.Before(now) {
+			delete(r.revoked, id)
+		}
+	}
+	r.revoked[jti] = expiresAt
+	return nil
+}
+
+func (r *RevokedTokenRepository) IsRevoked(_ context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.revoked[jti]
+	return ok, nil
+}
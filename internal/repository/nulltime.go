@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+// nullTime stores a zero time.Time as NULL, so that state timestamps can be
+// checked with IS NULL / IS NOT NULL in SQL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// timeScanner reads a nullable timestamp column into a time.Time, leaving
+// it zero for NULL.
+type timeScanner struct {
+	dst *time.Time
+}
+
+func (s timeScanner) Scan(src interface{}) error {
+	var nt sql.NullTime
+	if err := nt.Scan(src); err != nil {
+		return err
+	}
+	*s.dst = nt.Time
+	return nil
+}
+
+// nullString stores an empty string as NULL, for optional references such
+// as orders.pickup_point_id.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// stringScanner reads a nullable text column into a string, leaving it
+// empty for NULL.
+type stringScanner struct {
+	dst *string
+}
+
+func (s stringScanner) Scan(src interface{}) error {
+	var ns sql.NullString
+	if err := ns.Scan(src); err != nil {
+		return err
+	}
+	*s.dst = ns.String
+	return nil
+}
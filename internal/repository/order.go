@@ -1,49 +1,104 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
+
+	"homework/internal/apperr"
 	"homework/internal/models"
+	"homework/internal/packaging"
 )
 
 type Repository interface {
-	Create(o *models.Order) error
-	List(cursor string, limit int64, recipientID string) ([]*models.Order, error)
-	GetByID(tx *sql.Tx, id string) (*models.Order, error)
-	GetID(id string) (*models.Order, error)
-	Update(tx *sql.Tx, o *models.Order) error
-	Delete(id string) error
-	Deliver(id string) error
-	ClientReturn(id string) error
-	GetReturns(offset, limit int64, recipientID string) ([]*models.Order, error)
-	ReturnOrder(id string) error
-	AcceptOrder(id string) error
-	FetchPackaging(orderID string) ([]string, error)
-	UpdateTx(o *models.Order) error
+	Create(ctx context.Context, o *models.Order) error
+	CreateBatch(ctx context.Context, orders []*models.Order, atomic bool) ([]string, error)
+	List(ctx context.Context, cursor string, limit int64, recipientID string) ([]*models.Order, error)
+	Search(ctx context.Context, q OrderQuery) ([]*models.Order, error)
+	Export(ctx context.Context, f OrderFilter, fn func(*models.Order) error) error
+	GetByID(ctx context.Context, tx *sql.Tx, id string) (*models.Order, error)
+	GetID(ctx context.Context, id string) (*models.Order, error)
+	Update(ctx context.Context, tx *sql.Tx, o *models.Order) error
+	Delete(ctx context.Context, id string) error
+	Deliver(ctx context.Context, id string) error
+	ClientReturn(ctx context.Context, id string) error
+	GetReturns(ctx context.Context, q ReturnsQuery) ([]*models.Order, error)
+	ReturnOrder(ctx context.Context, id string) error
+	AcceptOrder(ctx context.Context, id string) error
+	FetchPackaging(ctx context.Context, orderID string) ([]string, error)
+	UpdateTx(ctx context.Context, o *models.Order) error
+	UpdateIfVersion(ctx context.Context, o *models.Order, version int64) error
+	ExpireOverdue(ctx context.Context, now time.Time, limit int) ([]*models.Order, error)
+	History(ctx context.Context, orderID string) ([]models.OrderEvent, error)
+}
+
+var (
+	ErrOrderNotFound   = apperr.New(apperr.NotFound, "order_not_found", "order not found")
+	ErrOrderExists     = apperr.New(apperr.AlreadyExists, "order_exists", "order already exists")
+	ErrVersionMismatch = apperr.New(apperr.Precondition, "version_mismatch", "order version mismatch")
+)
+
+// Postgres SQLSTATEs for unique and foreign key constraint violations.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation
 }
 
 type OrderRepository struct {
-	db *sql.DB
+	db        *sql.DB
+	packaging *packaging.Registry
 }
 
 func NewOrderRepository(db *sql.DB) *OrderRepository {
-	return &OrderRepository{db: db}
+	return &OrderRepository{db: db, packaging: packaging.Default}
+}
+
+// applyPackaging validates the order packaging against its weight and
+// stores the cost including packaging surcharges in o.FinalCost.
+func (r *OrderRepository) applyPackaging(o *models.Order) error {
+	finalCost, err := r.packaging.FinalCost(o.Packaging, o.Weight, o.Cost)
+	if err != nil {
+		return fmt.Errorf("order %s: %w", o.ID, err)
+	}
+	o.Packaging = o.Packaging.Names()
+	o.FinalCost = finalCost
+	return nil
 }
 
-func (r *OrderRepository) Create(o *models.Order) error {
-	tx, err := r.db.Begin()
+func (r *OrderRepository) Create(ctx context.Context, o *models.Order) error {
+	if err := r.applyPackaging(o); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	if err := ensureRecipients(ctx, tx, o); err != nil {
+		return err
+	}
+	o.Version = 1
 	query := `INSERT INTO orders (
 		id, recipient_id, storage_deadline, accepted_at, delivered_at,
-		returned_at, client_return_at, last_state_change, weight, cost
+		returned_at, client_return_at, last_state_change, weight, cost,
+		final_cost, version, pickup_point_id
 	) VALUES ($1,
 	          $2,
 	          $3,
@@ -53,25 +108,40 @@ func (r *OrderRepository) Create(o *models.Order) error {
 	          $7,
 	          $8,
 	          $9,
-	          $10)`
+	          $10,
+	          $11,
+	          $12,
+	          $13)`
 
-	_, err = tx.Exec(query,
+	_, err = tx.ExecContext(ctx, query,
 		o.ID,
 		o.RecipientID,
 		o.StorageDeadline,
-		o.AcceptedAt,
-		o.DeliveredAt,
-		o.ReturnedAt,
-		o.ClientReturnAt,
+		nullTime(o.AcceptedAt),
+		nullTime(o.DeliveredAt),
+		nullTime(o.ReturnedAt),
+		nullTime(o.ClientReturnAt),
 		o.LastStateChange,
 		o.Weight,
 		o.Cost,
+		o.FinalCost,
+		o.Version,
+		nullString(o.PickupPointID),
 	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("order %s: %w", o.ID, ErrOrderExists)
+	}
+	if isMissingPickupPoint(err) {
+		return fmt.Errorf("order %s: pickup point %s: %w", o.ID, o.PickupPointID, ErrPickupPointNotFound)
+	}
 	if err != nil {
 		return fmt.Errorf("create orders: %w", err)
 	}
 
-	if err := insertPackaging(tx, o.ID, o.Packaging); err != nil {
+	if err := insertPackaging(ctx, tx, o.ID, o.Packaging); err != nil {
+		return err
+	}
+	if err := recordEvent(ctx, tx, models.OrderEventCreated, nil, o); err != nil {
 		return err
 	}
 	if err := tx.Commit(); err != nil {
@@ -80,36 +150,52 @@ func (r *OrderRepository) Create(o *models.Order) error {
 	return nil
 }
 
-func insertPackaging(tx *sql.Tx, orderID string, packaging []string) error {
+func insertPackaging(ctx context.Context, tx *sql.Tx, orderID string, packaging []string) error {
 	for _, pkg := range packaging {
 		q := `INSERT INTO order_packaging(order_id, pkg_value) VALUES($1,$2)`
-		if _, err := tx.Exec(q, orderID, pkg); err != nil {
+		if _, err := tx.ExecContext(ctx, q, orderID, pkg); err != nil {
 			return fmt.Errorf("insertPackaging: %w", err)
 		}
 	}
 	return nil
 }
 
-func (r *OrderRepository) GetByID(tx *sql.Tx, id string) (*models.Order, error) {
-	o := &models.Order{}
-	query := `SELECT
-		id, recipient_id, storage_deadline,
+const orderColumns = `id, recipient_id, storage_deadline,
 		accepted_at, delivered_at, returned_at, client_return_at,
-		last_state_change, weight, cost
-	FROM orders WHERE id=$1 FOR UPDATE`
-	row := tx.QueryRow(query, id)
-	err := row.Scan(
+		last_state_change, weight, cost, final_cost, version, pickup_point_id`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanOrder reads the orderColumns of row into a new order; extra receives
+// any columns selected after them.
+func scanOrder(row rowScanner, extra ...any) (*models.Order, error) {
+	o := &models.Order{}
+	dest := []any{
 		&o.ID, &o.RecipientID, &o.StorageDeadline,
-		&o.AcceptedAt, &o.DeliveredAt, &o.ReturnedAt, &o.ClientReturnAt,
-		&o.LastStateChange, &o.Weight, &o.Cost,
-	)
+		timeScanner{&o.AcceptedAt}, timeScanner{&o.DeliveredAt},
+		timeScanner{&o.ReturnedAt}, timeScanner{&o.ClientReturnAt},
+		&o.LastStateChange, &o.Weight, &o.Cost, &o.FinalCost, &o.Version,
+		stringScanner{&o.PickupPointID},
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (r *OrderRepository) GetByID(ctx context.Context, tx *sql.Tx, id string) (*models.Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id=$1 FOR UPDATE`
+	o, err := scanOrder(tx.QueryRowContext(ctx, query, id))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
-	if err != nil || row.Err() != nil {
+	if err != nil {
 		return nil, fmt.Errorf("GetByID: %w", err)
 	}
-	rows, err := tx.Query(`SELECT pkg_value FROM order_packaging WHERE order_id=$1`, id)
+	rows, err := tx.QueryContext(ctx, `SELECT pkg_value FROM order_packaging WHERE order_id=$1`, id)
 	if err != nil {
 		return nil, fmt.Errorf("GetByID: %w", err)
 	}
@@ -129,39 +215,26 @@ func (r *OrderRepository) GetByID(tx *sql.Tx, id string) (*models.Order, error)
 	return o, nil
 }
 
-func (r *OrderRepository) GetID(id string) (*models.Order, error) {
-
-	o := &models.Order{}
-
-	query := `SELECT
-		id, recipient_id, storage_deadline,
-		accepted_at, delivered_at, returned_at, client_return_at,
-		last_state_change, weight, cost
-	FROM orders WHERE id=$1`
-	row := r.db.QueryRow(query, id)
-	err := row.Scan(
-		&o.ID, &o.RecipientID, &o.StorageDeadline,
-		&o.AcceptedAt, &o.DeliveredAt, &o.ReturnedAt, &o.ClientReturnAt,
-		&o.LastStateChange, &o.Weight, &o.Cost,
-	)
+func (r *OrderRepository) GetID(ctx context.Context, id string) (*models.Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id=$1`
+	o, err := scanOrder(r.db.QueryRowContext(ctx, query, id))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
-	if err != nil || row.Err() != nil {
+	if err != nil {
 		return nil, fmt.Errorf("GetByID: %w", err)
 	}
-	pkgs, err := r.FetchPackaging(id)
+	pkgs, err := r.FetchPackaging(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	o.Packaging = pkgs
 	return o, nil
-
 }
 
-func (r *OrderRepository) FetchPackaging(orderID string) ([]string, error) {
+func (r *OrderRepository) FetchPackaging(ctx context.Context, orderID string) ([]string, error) {
 	var result []string
-	rows, err := r.db.Query(`SELECT pkg_value FROM order_packaging WHERE order_id=$1`, orderID)
+	rows, err := r.db.QueryContext(ctx, `SELECT pkg_value FROM order_packaging WHERE order_id=$1`, orderID)
 	if err != nil {
 		return nil, fmt.Errorf("fetchPackaging: %w", err)
 	}
@@ -179,189 +252,211 @@ func (r *OrderRepository) FetchPackaging(orderID string) ([]string, error) {
 	return result, nil
 }
 
-func (r *OrderRepository) Update(tx *sql.Tx, o *models.Order) error {
-
+// Update writes o inside tx and bumps its version. The caller owns tx and is
+// responsible for committing it.
+func (r *OrderRepository) Update(ctx context.Context, tx *sql.Tx, o *models.Order) error {
 	query := `UPDATE orders SET
 		recipient_id=$1, storage_deadline=$2,
 		accepted_at=$3, delivered_at=$4,
 		returned_at=$5, client_return_at=$6,
-		last_state_change=$7, weight=$8, cost=$9
-	WHERE id=$10`
-	res, err := tx.Exec(query,
+		last_state_change=$7, weight=$8, cost=$9,
+		final_cost=$10, pickup_point_id=$11, version=version+1
+	WHERE id=$12
+	RETURNING version`
+	err := tx.QueryRowContext(ctx, query,
 		o.RecipientID, o.StorageDeadline,
-		o.AcceptedAt, o.DeliveredAt,
-		o.ReturnedAt, o.ClientReturnAt,
+		nullTime(o.AcceptedAt), nullTime(o.DeliveredAt),
+		nullTime(o.ReturnedAt), nullTime(o.ClientReturnAt),
 		o.LastStateChange, o.Weight, o.Cost,
+		o.FinalCost, nullString(o.PickupPointID),
 		o.ID,
-	)
+	).Scan(&o.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("order %s: %w", o.ID, ErrOrderNotFound)
+	}
+	if isMissingPickupPoint(err) {
+		return fmt.Errorf("order %s: pickup point %s: %w", o.ID, o.PickupPointID, ErrPickupPointNotFound)
+	}
 	if err != nil {
 		return fmt.Errorf("update order: %w", err)
 	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return fmt.Errorf("order %s not found", o.ID)
-	}
 
-	if _, err := tx.Exec(`DELETE FROM order_packaging WHERE order_id=$1`, o.ID); err != nil {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM order_packaging WHERE order_id=$1`, o.ID); err != nil {
 		return fmt.Errorf("delete packaging: %w", err)
 	}
-	if err := insertPackaging(tx, o.ID, o.Packaging); err != nil {
-		return err
+	return insertPackaging(ctx, tx, o.ID, o.Packaging)
+}
+
+// UpdateTx overwrites the stored order with o. If o carries a different
+// state than the stored one, the change must be a legal transition.
+func (r *OrderRepository) UpdateTx(ctx context.Context, o *models.Order) error {
+	return r.updateTx(ctx, o, 0)
+}
+
+// UpdateIfVersion works like UpdateTx but fails with ErrVersionMismatch
+// unless the stored order still has the given version.
+func (r *OrderRepository) UpdateIfVersion(ctx context.Context, o *models.Order, version int64) error {
+	if version <= 0 {
+		return fmt.Errorf("order %s: version %d: %w", o.ID, version, ErrVersionMismatch)
 	}
-	return tx.Commit()
+	return r.updateTx(ctx, o, version)
 }
 
-func (r *OrderRepository) UpdateTx(o *models.Order) error {
-	tx, err := r.db.Begin()
+// updateTx implements UpdateTx and UpdateIfVersion; version 0 skips the
+// version check.
+func (r *OrderRepository) updateTx(ctx context.Context, o *models.Order, version int64) error {
+	if err := r.applyPackaging(o); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	query := `UPDATE orders SET
-		recipient_id=$1, storage_deadline=$2,
-		accepted_at=$3, delivered_at=$4,
-		returned_at=$5, client_return_at=$6,
-		last_state_change=$7, weight=$8, cost=$9
-	WHERE id=$10`
-	res, err := tx.Exec(query,
-		o.RecipientID, o.StorageDeadline,
-		o.AcceptedAt, o.DeliveredAt,
-		o.ReturnedAt, o.ClientReturnAt,
-		o.LastStateChange, o.Weight, o.Cost,
-		o.ID,
-	)
+	current, err := r.GetByID(ctx, tx, o.ID)
 	if err != nil {
-		return fmt.Errorf("update order: %w", err)
+		return err
 	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return fmt.Errorf("order %s not found", o.ID)
+	if err := CheckUpdate(current, o, version); err != nil {
+		return err
+	}
+	if err := claimShelf(ctx, tx, current, o); err != nil {
+		return err
+	}
+	if err := ensureChangedRecipient(ctx, tx, current, o); err != nil {
+		return err
 	}
 
-	if _, err := tx.Exec(`DELETE FROM order_packaging WHERE order_id=$1`, o.ID); err != nil {
-		return fmt.Errorf("delete packaging: %w", err)
+	if err := r.Update(ctx, tx, o); err != nil {
+		return err
 	}
-	if err := insertPackaging(tx, o.ID, o.Packaging); err != nil {
+	if err := recordEvent(ctx, tx, models.OrderEventUpdated, current, o); err != nil {
 		return err
 	}
 	return tx.Commit()
 }
 
-func (r *OrderRepository) Delete(id string) error {
-	res, err := r.db.Exec(`DELETE FROM orders WHERE id=$1`, id)
-	if err != nil {
-		return fmt.Errorf("delete order: %w", err)
+// CheckUpdate validates overwriting current with o: the order must exist,
+// have the expected version unless version is 0, and any state change must
+// be a legal transition. It is shared by the repository implementations.
+func CheckUpdate(current, o *models.Order, version int64) error {
+	if current == nil {
+		return fmt.Errorf("order %s: %w", o.ID, ErrOrderNotFound)
+	}
+	if version != 0 && current.Version != version {
+		return fmt.Errorf("order %s: version %d, expected %d: %w", o.ID, current.Version, version, ErrVersionMismatch)
 	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return fmt.Errorf("order %s not found", id)
+	from, to := current.CurrentState(), o.CurrentState()
+	if from != to && !models.CanTransition(from, to) {
+		return fmt.Errorf("order %s: %w", o.ID, &models.TransitionError{From: from, To: to})
 	}
 	return nil
 }
 
-func (r *OrderRepository) Deliver(id string) error {
-	tx, err := r.db.Begin()
+func (r *OrderRepository) Delete(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	o, err := r.GetByID(tx, id)
+	o, err := r.GetByID(ctx, tx, id)
 	if err != nil {
 		return err
 	}
 	if o == nil {
-		return fmt.Errorf("order %s not found", id)
+		return fmt.Errorf("order %s: %w", id, ErrOrderNotFound)
 	}
-	o.UpdateState(models.OrderStateDelivered)
-	o.DeliveredAt = time.Now().UTC()
-	o.LastStateChange = time.Now().UTC()
-
-	if err := r.Update(tx, o); err != nil {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM orders WHERE id=$1`, id); err != nil {
+		return fmt.Errorf("delete order: %w", err)
+	}
+	if err := recordEvent(ctx, tx, models.OrderEventDeleted, o, nil); err != nil {
 		return err
 	}
 	return tx.Commit()
+}
 
+func (r *OrderRepository) Deliver(ctx context.Context, id string) error {
+	return r.transition(ctx, id, models.OrderStateDelivered)
 }
 
-func (r *OrderRepository) ClientReturn(id string) error {
-	tx, err := r.db.Begin()
+func (r *OrderRepository) ClientReturn(ctx context.Context, id string) error {
+	return r.transition(ctx, id, models.OrderStateClientRtn)
+}
+
+// transition locks the order row and moves it to the given state if the
+// state machine in models.Order allows it.
+func (r *OrderRepository) transition(ctx context.Context, id string, state models.OrderState) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	o, err := r.GetByID(tx, id)
+	o, err := r.GetByID(ctx, tx, id)
 	if err != nil {
 		return err
 	}
 	if o == nil {
-		return fmt.Errorf("order %s not found", id)
+		return fmt.Errorf("order %s: %w", id, ErrOrderNotFound)
 	}
-
-	o.UpdateState(models.OrderStateClientRtn)
-	o.ClientReturnAt = time.Now().UTC()
-	o.LastStateChange = time.Now().UTC()
-
-	if err := r.Update(tx, o); err != nil {
+	if err := r.applyTransition(ctx, tx, o, state); err != nil {
 		return err
 	}
 	return tx.Commit()
 }
 
-func (r *OrderRepository) GetReturns(offset int64, limit int64, recipientID string) ([]*models.Order, error) {
-	if limit <= 0 {
-		limit = 10
+// applyTransition moves an order locked by tx to state, claiming a shelf
+// place if the state needs one, writes it back and records the transition in
+// the order history.
+func (r *OrderRepository) applyTransition(ctx context.Context, tx *sql.Tx, o *models.Order, state models.OrderState) error {
+	before := *o
+	if err := o.UpdateState(state); err != nil {
+		return fmt.Errorf("order %s: %w", o.ID, err)
 	}
-
-	var b strings.Builder
-	b.WriteString(`SELECT id FROM orders WHERE client_return_at IS NOT NULL`)
-
-	if recipientID != "" {
-		b.WriteString(` AND recipient_id = $3`)
+	if err := claimShelf(ctx, tx, &before, o); err != nil {
+		return err
 	}
-
-	b.WriteString(` ORDER BY id ASC LIMIT $1 OFFSET $2`)
-
-	var args []any
-	args = append(args, limit, offset)
-
-	if recipientID != "" {
-		args = append(args, recipientID)
+	if err := r.Update(ctx, tx, o); err != nil {
+		return err
 	}
+	return recordEvent(ctx, tx, models.OrderEventStateChanged, &before, o)
+}
 
-	tx, err := r.db.Begin()
+// ExpireOverdue returns up to limit accepted, undelivered orders whose storage
+// deadline is before now to the courier. Rows locked by a concurrent caller
+// are skipped, so several instances can run it at the same time.
+func (r *OrderRepository) ExpireOverdue(ctx context.Context, now time.Time, limit int) ([]*models.Order, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	rows, err := tx.Query(b.String(), args...)
+	query := `SELECT ` + orderColumns + ` FROM orders
+	WHERE accepted_at IS NOT NULL
+	  AND delivered_at IS NULL
+	  AND client_return_at IS NULL
+	  AND returned_at IS NULL
+	  AND storage_deadline < $1
+	ORDER BY storage_deadline, id
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED`
+	orders, err := r.queryOrders(ctx, tx, query, now, limit)
 	if err != nil {
-		return nil, fmt.Errorf("GetReturns: %w", err)
+		return nil, fmt.Errorf("ExpireOverdue: %w", err)
 	}
-	defer rows.Close()
-
-	var result []*models.Order
-	for rows.Next() {
-		var id string
-		if err := rows.Scan(&id); err != nil {
+	for _, o := range orders {
+		if err := r.applyTransition(ctx, tx, o, models.OrderStateReturned); err != nil {
 			return nil, err
 		}
-		if rows.Err() != nil {
-			return nil, rows.Err()
-		}
-		o, err := r.GetByID(tx, id)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, o)
 	}
-	return result, tx.Commit()
+	return orders, tx.Commit()
 }
 
-func (r *OrderRepository) List(cursor string, limit int64, recipientID string) ([]*models.Order, error) {
+func (r *OrderRepository) List(ctx context.Context, cursor string, limit int64, recipientID string) ([]*models.Order, error) {
 	if limit <= 0 {
 		limit = 10
 	}
@@ -373,7 +468,7 @@ func (r *OrderRepository) List(cursor string, limit int64, recipientID string) (
 		paramIndex = 1
 	)
 
-	sb.WriteString("SELECT id FROM orders")
+	sb.WriteString("SELECT " + orderColumns + " FROM orders")
 
 	if cursor != "" {
 		conditions = append(conditions, fmt.Sprintf("id > $%d", paramIndex))
@@ -394,87 +489,81 @@ func (r *OrderRepository) List(cursor string, limit int64, recipientID string) (
 
 	sb.WriteString(fmt.Sprintf(" ORDER BY id ASC LIMIT $%d", paramIndex))
 	args = append(args, limit)
-	paramIndex++
-
-	query := sb.String()
 
-	tx, err := r.db.Begin()
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	rows, err := tx.Query(query, args...)
+	orders, err := r.queryOrders(ctx, r.db, sb.String(), args...)
 	if err != nil {
 		return nil, fmt.Errorf("list orders: %w", err)
 	}
-	defer rows.Close()
+	return orders, nil
+}
 
+// scanOrders reads and closes rows, so the connection is free for the next
+// query of the same transaction.
+func scanOrders(rows *sql.Rows) ([]*models.Order, error) {
+	defer rows.Close()
 	var orders []*models.Order
-
 	for rows.Next() {
-		var id string
-		if err := rows.Scan(&id); err != nil {
-			return nil, err
-		}
-		o, err := r.GetByID(tx, id)
+		o, err := scanOrder(rows)
 		if err != nil {
 			return nil, err
 		}
 		orders = append(orders, o)
 	}
-	if rows.Err() != nil {
-		return nil, rows.Err()
-	}
+	return orders, rows.Err()
+}
 
-	return orders, tx.Commit()
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
 }
 
-func (r *OrderRepository) AcceptOrder(id string) error {
-	tx, err := r.db.Begin()
+// queryOrders runs a query selecting orderColumns and loads the packaging of
+// all returned orders with a single extra query.
+func (r *OrderRepository) queryOrders(ctx context.Context, q querier, query string, args ...any) ([]*models.Order, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer tx.Rollback()
-
-	o, err := r.GetByID(tx, id)
+	orders, err := scanOrders(rows)
 	if err != nil {
-		return err
-	}
-	if o == nil {
-		return fmt.Errorf("order %s not found", id)
+		return nil, err
 	}
-
-	o.UpdateState(models.OrderStateAccepted)
-	o.AcceptedAt = time.Now().UTC()
-	o.LastStateChange = time.Now().UTC()
-
-	if err := r.Update(tx, o); err != nil {
-		return err
+	if err := r.attachPackaging(ctx, q, orders); err != nil {
+		return nil, err
 	}
-	return tx.Commit()
+	return orders, nil
 }
 
-func (r *OrderRepository) ReturnOrder(id string) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
+func (r *OrderRepository) attachPackaging(ctx context.Context, q querier, orders []*models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	byID := make(map[string]*models.Order, len(orders))
+	ids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		byID[o.ID] = o
+		ids = append(ids, o.ID)
 	}
-	defer tx.Rollback()
 
-	o, err := r.GetByID(tx, id)
+	rows, err := q.QueryContext(ctx, `SELECT order_id, pkg_value FROM order_packaging WHERE order_id = ANY($1)`, pq.Array(ids))
 	if err != nil {
-		return err
+		return fmt.Errorf("attachPackaging: %w", err)
 	}
-	if o == nil {
-		return fmt.Errorf("order %s not found", id)
+	defer rows.Close()
+	for rows.Next() {
+		var orderID, pkg string
+		if err := rows.Scan(&orderID, &pkg); err != nil {
+			return err
+		}
+		o := byID[orderID]
+		o.Packaging = append(o.Packaging, pkg)
 	}
-	o.UpdateState(models.OrderStateReturned)
-	o.ReturnedAt = time.Now().UTC()
-	o.LastStateChange = time.Now().UTC()
+	return rows.Err()
+}
 
-	if err := r.Update(tx, o); err != nil {
-		return err
-	}
-	return tx.Commit()
+func (r *OrderRepository) AcceptOrder(ctx context.Context, id string) error {
+	return r.transition(ctx, id, models.OrderStateAccepted)
+}
+
+func (r *OrderRepository) ReturnOrder(ctx context.Context, id string) error {
+	return r.transition(ctx, id, models.OrderStateReturned)
 }
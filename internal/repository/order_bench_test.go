@@ -0,0 +1,93 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"homework/internal/models"
+)
+
+const benchOrders = 1000
+
+func seedBenchOrders(b *testing.B) {
+	b.Helper()
+	ctx := context.Background()
+	if _, err := db.Exec("DELETE FROM order_packaging"); err != nil {
+		b.Fatalf("clean order_packaging: %v", err)
+	}
+	if _, err := db.Exec("DELETE FROM orders"); err != nil {
+		b.Fatalf("clean orders: %v", err)
+	}
+	for i := 0; i < benchOrders; i++ {
+		o := &models.Order{
+			ID:              fmt.Sprintf("bench%05d", i),
+			RecipientID:     "bench-user",
+			StorageDeadline: time.Now().Add(24 * time.Hour),
+			LastStateChange: time.Now().UTC(),
+			Weight:          1,
+			Cost:            100,
+			Packaging:       []string{"box", "film"},
+		}
+		if err := repo.Create(ctx, o); err != nil {
+			b.Fatalf("create order: %v", err)
+		}
+	}
+}
+
+// listPerRow is the previous List implementation: one SELECT for the ids and
+// a locking GetByID (two more queries) for every row.
+func listPerRow(ctx context.Context, limit int64) ([]*models.Order, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM orders ORDER BY id ASC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	orders := make([]*models.Order, 0, len(ids))
+	for _, id := range ids {
+		o, err := repo.GetByID(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, tx.Commit()
+}
+
+func BenchmarkList(b *testing.B) {
+	seedBenchOrders(b)
+	ctx := context.Background()
+
+	b.Run("PerRow", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := listPerRow(ctx, benchOrders); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("SetBased", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.List(ctx, "", benchOrders, ""); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
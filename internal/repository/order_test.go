@@ -1,6 +1,7 @@
 package repository_test
 
 import (
+	"context"
 	"database/sql"
 	"homework/internal/models"
 	"log"
@@ -36,11 +37,13 @@ func TestMain(m *testing.M) {
 
 	db.Exec("DELETE FROM order_packaging")
 	db.Exec("DELETE FROM orders")
+	db.Exec("DELETE FROM order_events")
 
 	os.Exit(code)
 }
 
 func TestCreateGetDelete(t *testing.T) {
+	ctx := context.Background()
 	o := &models.Order{
 		ID:              "test-100",
 		RecipientID:     "user42",
@@ -48,55 +51,87 @@ func TestCreateGetDelete(t *testing.T) {
 		LastStateChange: time.Now().UTC(),
 		Packaging:       []string{"box", "film"},
 	}
-	err := repo.Create(o)
+	err := repo.Create(ctx, o)
 	assert.NoError(t, err)
 
-	o2, err := repo.GetID("test-100")
+	o2, err := repo.GetID(ctx, "test-100")
 	assert.NoError(t, err)
 	assert.NotNil(t, o2)
 	assert.Equal(t, "user42", o2.RecipientID)
 	assert.ElementsMatch(t, []string{"box", "film"}, o2.Packaging)
+	assert.Equal(t, 21.0, o2.FinalCost)
 
-	err = repo.Delete("test-100")
+	err = repo.Delete(ctx, "test-100")
 	assert.NoError(t, err)
 
-	o3, err := repo.GetID("test-100")
+	o3, err := repo.GetID(ctx, "test-100")
 	assert.NoError(t, err)
 	assert.Nil(t, o3)
 }
 
 func TestDeliverAndReturn(t *testing.T) {
+	ctx := context.Background()
 	o := &models.Order{
 		ID:              "test-deliver-1",
 		RecipientID:     "userA",
 		LastStateChange: time.Now().UTC(),
 	}
-	err := repo.Create(o)
+	err := repo.Create(ctx, o)
 	assert.NoError(t, err)
 
-	err = repo.Deliver(o.ID)
+	err = repo.ClientReturn(ctx, o.ID)
+	assert.ErrorIs(t, err, models.ErrInvalidTransition)
+
+	err = repo.AcceptOrder(ctx, o.ID)
+	assert.NoError(t, err)
+
+	err = repo.Deliver(ctx, o.ID)
 	assert.NoError(t, err)
 
-	o2, err := repo.GetID(o.ID)
+	o2, err := repo.GetID(ctx, o.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, models.OrderStateDelivered, o2.CurrentState())
 
-	err = repo.ClientReturn(o.ID)
+	err = repo.ClientReturn(ctx, o.ID)
 	assert.NoError(t, err)
 
-	o3, _ := repo.GetID(o.ID)
+	o3, _ := repo.GetID(ctx, o.ID)
 	assert.Equal(t, models.OrderStateClientRtn, o3.CurrentState())
+
+	err = repo.Deliver(ctx, o.ID)
+	assert.ErrorIs(t, err, models.ErrInvalidTransition)
+
+	err = repo.Deliver(ctx, "test-deliver-missing")
+	assert.ErrorIs(t, err, repository.ErrOrderNotFound)
 }
 
 func TestGetReturns(t *testing.T) {
+	ctx := context.Background()
 	o1 := &models.Order{ID: "rtn-1", LastStateChange: time.Now().UTC()}
 	o2 := &models.Order{ID: "rtn-2", LastStateChange: time.Now().UTC()}
-	_ = repo.Create(o1)
-	_ = repo.Create(o2)
-	_ = repo.ClientReturn("rtn-2")
+	_ = repo.Create(ctx, o1)
+	_ = repo.Create(ctx, o2)
+	_ = repo.AcceptOrder(ctx, "rtn-2")
+	_ = repo.Deliver(ctx, "rtn-2")
+	_ = repo.ClientReturn(ctx, "rtn-2")
 
-	list, err := repo.GetReturns(0, 10, "")
+	list, err := repo.GetReturns(ctx, repository.ReturnsQuery{Limit: 10})
 	assert.NoError(t, err)
 	assert.Len(t, list, 1)
 	assert.Equal(t, "rtn-2", list[0].ID)
 }
+
+func TestCreateBatchSkippedOrdersAddNoRecipients(t *testing.T) {
+	ctx := context.Background()
+	o := &models.Order{ID: "batch-dup-1", RecipientID: "batch-user1", LastStateChange: time.Now().UTC()}
+	assert.NoError(t, repo.Create(ctx, o))
+
+	dup := &models.Order{ID: "batch-dup-1", RecipientID: "batch-ghost", LastStateChange: time.Now().UTC()}
+	existing, err := repo.CreateBatch(ctx, []*models.Order{dup}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"batch-dup-1"}, existing)
+
+	var n int
+	assert.NoError(t, db.QueryRow(`SELECT count(*) FROM recipients WHERE id = 'batch-ghost'`).Scan(&n))
+	assert.Zero(t, n)
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+)
+
+type PickupPointRepository interface {
+	CreatePickupPoint(ctx context.Context, p *models.PickupPoint) error
+	GetPickupPoint(ctx context.Context, id string) (*models.PickupPoint, error)
+	ListPickupPoints(ctx context.Context) ([]*models.PickupPoint, error)
+	UpdatePickupPoint(ctx context.Context, p *models.PickupPoint) error
+}
+
+var (
+	ErrPickupPointNotFound = apperr.New(apperr.NotFound, "pickup_point_not_found", "pickup point not found")
+	ErrPickupPointExists   = apperr.New(apperr.AlreadyExists, "pickup_point_exists", "pickup point already exists")
+	ErrPickupPointFull     = apperr.New(apperr.Conflict, "pickup_point_full", "pickup point has no free shelf places")
+)
+
+// shelfCondition selects the orders that take a shelf place, see
+// models.Order.OnShelf.
+const shelfCondition = stateExpr + ` IN ('accepted', 'client_rtn')`
+
+const pickupPointColumns = `id, address, working_hours, capacity, created_at,
+	(SELECT COUNT(*) FROM orders WHERE pickup_point_id = pickup_points.id AND ` + shelfCondition + `)`
+
+// isMissingPickupPoint reports whether err is a write of an order naming a
+// pickup point that does not exist.
+func isMissingPickupPoint(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation && pqErr.Constraint == "orders_pickup_point_fk"
+}
+
+type PostgresPickupPointRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresPickupPointRepository(db *sql.DB) *PostgresPickupPointRepository {
+	return &PostgresPickupPointRepository{db: db}
+}
+
+func scanPickupPoint(row rowScanner) (*models.PickupPoint, error) {
+	var p models.PickupPoint
+	if err := row.Scan(&p.ID, &p.Address, &p.WorkingHours, &p.Capacity, &p.CreatedAt, &p.Occupied); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *PostgresPickupPointRepository) CreatePickupPoint(ctx context.Context, p *models.PickupPoint) error {
+	query := `INSERT INTO pickup_points (id, address, working_hours, capacity)
+	VALUES ($1, $2, $3, $4) RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query, p.ID, p.Address, p.WorkingHours, p.Capacity).Scan(&p.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("pickup point %s: %w", p.ID, ErrPickupPointExists)
+	}
+	if err != nil {
+		return fmt.Errorf("CreatePickupPoint: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresPickupPointRepository) GetPickupPoint(ctx context.Context, id string) (*models.PickupPoint, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+pickupPointColumns+` FROM pickup_points WHERE id = $1`, id)
+	p, err := scanPickupPoint(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("pickup point %s: %w", id, ErrPickupPointNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetPickupPoint: %w", err)
+	}
+	return p, nil
+}
+
+func (r *PostgresPickupPointRepository) ListPickupPoints(ctx context.Context) ([]*models.PickupPoint, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+pickupPointColumns+` FROM pickup_points ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListPickupPoints: %w", err)
+	}
+	defer rows.Close()
+	points := make([]*models.PickupPoint, 0)
+	for rows.Next() {
+		p, err := scanPickupPoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListPickupPoints: %w", err)
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListPickupPoints: %w", err)
+	}
+	return points, nil
+}
+
+// UpdatePickupPoint overwrites the address, hours and capacity. Lowering the
+// capacity below the current occupancy is allowed; the point then simply
+// accepts nothing until enough orders leave the shelf.
+func (r *PostgresPickupPointRepository) UpdatePickupPoint(ctx context.Context, p *models.PickupPoint) error {
+	query := `UPDATE pickup_points SET address = $2, working_hours = $3, capacity = $4
+	WHERE id = $1 RETURNING ` + pickupPointColumns
+	updated, err := scanPickupPoint(r.db.QueryRowContext(ctx, query, p.ID, p.Address, p.WorkingHours, p.Capacity))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("pickup point %s: %w", p.ID, ErrPickupPointNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("UpdatePickupPoint: %w", err)
+	}
+	*p = *updated
+	return nil
+}
+
+// TakesShelf reports whether writing next over prev needs a shelf place that
+// prev does not hold: the parcel arrives on the shelf, by acceptance or a
+// client return, or moves to another point while on it. It is shared by the
+// repository implementations.
+func TakesShelf(prev, next *models.Order) bool {
+	if next.PickupPointID == "" || !next.OnShelf() {
+		return false
+	}
+	return !prev.OnShelf() || prev.PickupPointID != next.PickupPointID
+}
+
+// claimShelf reserves a shelf place for next if TakesShelf says it needs one.
+func claimShelf(ctx context.Context, tx *sql.Tx, prev, next *models.Order) error {
+	if !TakesShelf(prev, next) {
+		return nil
+	}
+	return reserveShelf(ctx, tx, next.PickupPointID)
+}
+
+// reserveShelf fails with ErrPickupPointFull unless the point has a free
+// shelf place. It locks the point row, so concurrent acceptances at the
+// same point are serialized and cannot overfill it.
+func reserveShelf(ctx context.Context, tx *sql.Tx, pointID string) error {
+	var capacity int
+	err := tx.QueryRowContext(ctx, `SELECT capacity FROM pickup_points WHERE id = $1 FOR UPDATE`, pointID).Scan(&capacity)
+	if err != nil {
+		return fmt.Errorf("reserve shelf: %w", err)
+	}
+	if capacity == 0 {
+		return nil
+	}
+	var occupied int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders WHERE pickup_point_id = $1 AND `+shelfCondition, pointID).Scan(&occupied)
+	if err != nil {
+		return fmt.Errorf("reserve shelf: %w", err)
+	}
+	if occupied >= capacity {
+		return fmt.Errorf("pickup point %s: %d of %d places taken: %w", pointID, occupied, capacity, ErrPickupPointFull)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+)
+
+type RecipientRepository interface {
+	CreateRecipient(ctx context.Context, rc *models.Recipient) error
+	GetRecipient(ctx context.Context, id string) (*models.Recipient, error)
+	ListRecipients(ctx context.Context, after string, limit int) ([]*models.Recipient, error)
+	UpdateRecipient(ctx context.Context, rc *models.Recipient) error
+	DeleteRecipient(ctx context.Context, id string) error
+	// EnsureRecipient returns the recipient, creating a bare active one if
+	// it does not exist yet.
+	EnsureRecipient(ctx context.Context, id string) (*models.Recipient, error)
+}
+
+var (
+	ErrRecipientNotFound  = apperr.New(apperr.NotFound, "recipient_not_found", "recipient not found")
+	ErrRecipientExists    = apperr.New(apperr.AlreadyExists, "recipient_exists", "recipient already exists")
+	ErrRecipientHasOrders = apperr.New(apperr.Conflict, "recipient_has_orders", "recipient still has orders")
+)
+
+const recipientColumns = `id, name, phone, email, status, created_at, updated_at`
+
+type PostgresRecipientRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresRecipientRepository(db *sql.DB) *PostgresRecipientRepository {
+	return &PostgresRecipientRepository{db: db}
+}
+
+func scanRecipient(row rowScanner) (*models.Recipient, error) {
+	var rc models.Recipient
+	err := row.Scan(&rc.ID, &rc.Name, &rc.Phone, &rc.Email, &rc.Status, &rc.CreatedAt, &rc.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rc, nil
+}
+
+func (r *PostgresRecipientRepository) CreateRecipient(ctx context.Context, rc *models.Recipient) error {
+	query := `INSERT INTO recipients (id, name, phone, email, status)
+	VALUES ($1, $2, $3, $4, $5) RETURNING created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, rc.ID, rc.Name, rc.Phone, rc.Email, rc.Status).
+		Scan(&rc.CreatedAt, &rc.UpdatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("recipient %s: %w", rc.ID, ErrRecipientExists)
+	}
+	if err != nil {
+		return fmt.Errorf("CreateRecipient: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRecipientRepository) GetRecipient(ctx context.Context, id string) (*models.Recipient, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+recipientColumns+` FROM recipients WHERE id = $1`, id)
+	rc, err := scanRecipient(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("recipient %s: %w", id, ErrRecipientNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetRecipient: %w", err)
+	}
+	return rc, nil
+}
+
+// ListRecipients returns up to limit recipients ordered by id, starting after
+// the given id.
+func (r *PostgresRecipientRepository) ListRecipients(ctx context.Context, after string, limit int) ([]*models.Recipient, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+recipientColumns+` FROM recipients
+	WHERE id > $1 ORDER BY id LIMIT $2`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ListRecipients: %w", err)
+	}
+	defer rows.Close()
+	recipients := make([]*models.Recipient, 0)
+	for rows.Next() {
+		rc, err := scanRecipient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListRecipients: %w", err)
+		}
+		recipients = append(recipients, rc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListRecipients: %w", err)
+	}
+	return recipients, nil
+}
+
+func (r *PostgresRecipientRepository) UpdateRecipient(ctx context.Context, rc *models.Recipient) error {
+	query := `UPDATE recipients SET name = $2, phone = $3, email = $4, status = $5, updated_at = NOW()
+	WHERE id = $1 RETURNING created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, rc.ID, rc.Name, rc.Phone, rc.Email, rc.Status).
+		Scan(&rc.CreatedAt, &rc.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("recipient %s: %w", rc.ID, ErrRecipientNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("UpdateRecipient: %w", err)
+	}
+	return nil
+}
+
+// DeleteRecipient removes a recipient without orders. The foreign key from
+// orders keeps recipients with parcels, reported as ErrRecipientHasOrders.
+func (r *PostgresRecipientRepository) DeleteRecipient(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM recipients WHERE id = $1`, id)
+	if isForeignKeyViolation(err) {
+		return fmt.Errorf("recipient %s: %w", id, ErrRecipientHasOrders)
+	}
+	if err != nil {
+		return fmt.Errorf("DeleteRecipient: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("DeleteRecipient: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("recipient %s: %w", id, ErrRecipientNotFound)
+	}
+	return nil
+}
+
+func (r *PostgresRecipientRepository) EnsureRecipient(ctx context.Context, id string) (*models.Recipient, error) {
+	if _, err := r.db.ExecContext(ctx, ensureRecipientsQuery, pq.Array([]string{id})); err != nil {
+		return nil, fmt.Errorf("EnsureRecipient: %w", err)
+	}
+	return r.GetRecipient(ctx, id)
+}
+
+// ensureRecipientsQuery adds bare rows for the recipient IDs in $1 that do
+// not exist yet.
+const ensureRecipientsQuery = `INSERT INTO recipients (id) SELECT unnest($1::text[])
+	ON CONFLICT (id) DO NOTHING`
+
+// ensureRecipients makes the recipients of orders exist inside tx, so the
+// foreign key from orders holds whatever path the orders were written by.
+// Whether an unknown recipient is acceptable is decided above the repository.
+func ensureRecipients(ctx context.Context, tx *sql.Tx, orders ...*models.Order) error {
+	ids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.RecipientID)
+	}
+	if _, err := tx.ExecContext(ctx, ensureRecipientsQuery, pq.Array(ids)); err != nil {
+		return fmt.Errorf("ensure recipients: %w", err)
+	}
+	return nil
+}
+
+// ensureChangedRecipient is ensureRecipients for an update of current to o:
+// only a recipient the update switches to can be missing.
+func ensureChangedRecipient(ctx context.Context, tx *sql.Tx, current, o *models.Order) error {
+	if o.RecipientID == current.RecipientID {
+		return nil
+	}
+	return ensureRecipients(ctx, tx, o)
+}
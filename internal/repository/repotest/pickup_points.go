@@ -0,0 +1,110 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+// RunPickupPointRepository runs the pickup point suite. newRepos must return
+// empty repositories sharing one storage for every call, since capacity is
+// checked by the order repository.
+func RunPickupPointRepository(t *testing.T, newRepos func(t *testing.T) (repository.Repository, repository.PickupPointRepository)) {
+	tests := map[string]func(t *testing.T, orders repository.Repository, points repository.PickupPointRepository){
+		"CRUD":     testPickupPointCRUD,
+		"Capacity": testPickupPointCapacity,
+		"Filters":  testPickupPointFilters,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			orders, points := newRepos(t)
+			test(t, orders, points)
+		})
+	}
+}
+
+func newPickupPoint(id string, capacity int) *models.PickupPoint {
+	return &models.PickupPoint{ID: id, Address: "Main st. 1", WorkingHours: "10-22", Capacity: capacity}
+}
+
+func newOrderAt(id, pointID string) *models.Order {
+	o := newOrder(id, "user1")
+	o.PickupPointID = pointID
+	return o
+}
+
+func testPickupPointCRUD(t *testing.T, _ repository.Repository, points repository.PickupPointRepository) {
+	ctx := context.Background()
+	require.NoError(t, points.CreatePickupPoint(ctx, newPickupPoint("pvz1", 10)))
+	assert.ErrorIs(t, points.CreatePickupPoint(ctx, newPickupPoint("pvz1", 10)), repository.ErrPickupPointExists)
+
+	p := newPickupPoint("pvz1", 20)
+	p.WorkingHours = "09-21"
+	require.NoError(t, points.UpdatePickupPoint(ctx, p))
+	got, err := points.GetPickupPoint(ctx, "pvz1")
+	require.NoError(t, err)
+	assert.Equal(t, 20, got.Capacity)
+	assert.Equal(t, "09-21", got.WorkingHours)
+
+	_, err = points.GetPickupPoint(ctx, "ghost")
+	assert.ErrorIs(t, err, repository.ErrPickupPointNotFound)
+	assert.ErrorIs(t, points.UpdatePickupPoint(ctx, newPickupPoint("ghost", 1)), repository.ErrPickupPointNotFound)
+}
+
+func testPickupPointCapacity(t *testing.T, orders repository.Repository, points repository.PickupPointRepository) {
+	ctx := context.Background()
+	require.NoError(t, points.CreatePickupPoint(ctx, newPickupPoint("pvz1", 1)))
+	for _, id := range []string{"ord1", "ord2"} {
+		require.NoError(t, orders.Create(ctx, newOrderAt(id, "pvz1")))
+	}
+	assert.ErrorIs(t, orders.Create(ctx, newOrderAt("ord3", "ghost")), repository.ErrPickupPointNotFound)
+
+	require.NoError(t, orders.AcceptOrder(ctx, "ord1"))
+	assert.ErrorIs(t, orders.AcceptOrder(ctx, "ord2"), repository.ErrPickupPointFull)
+
+	p, err := points.GetPickupPoint(ctx, "pvz1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.Occupied)
+
+	require.NoError(t, orders.Deliver(ctx, "ord1"))
+	require.NoError(t, orders.AcceptOrder(ctx, "ord2"))
+
+	// A client return puts the parcel back on the shelf.
+	assert.ErrorIs(t, orders.ClientReturn(ctx, "ord1"), repository.ErrPickupPointFull)
+
+	// So does an update that sets accepted_at instead of calling AcceptOrder.
+	require.NoError(t, orders.Create(ctx, newOrderAt("ord3", "pvz1")))
+	o, err := orders.GetID(ctx, "ord3")
+	require.NoError(t, err)
+	require.NoError(t, o.UpdateState(models.OrderStateAccepted))
+	assert.ErrorIs(t, orders.UpdateTx(ctx, o), repository.ErrPickupPointFull)
+}
+
+func testPickupPointFilters(t *testing.T, orders repository.Repository, points repository.PickupPointRepository) {
+	ctx := context.Background()
+	for _, id := range []string{"pvz1", "pvz2"} {
+		require.NoError(t, points.CreatePickupPoint(ctx, newPickupPoint(id, 0)))
+	}
+	for id, point := range map[string]string{"ord1": "pvz1", "ord2": "pvz2", "ord3": "pvz1"} {
+		require.NoError(t, orders.Create(ctx, newOrderAt(id, point)))
+		require.NoError(t, orders.AcceptOrder(ctx, id))
+		require.NoError(t, orders.Deliver(ctx, id))
+		require.NoError(t, orders.ClientReturn(ctx, id))
+	}
+
+	page, err := orders.Search(ctx, repository.OrderQuery{
+		Filter: repository.OrderFilter{PickupPointID: "pvz1"},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ord1", "ord3"}, ids(page))
+
+	page, err = orders.GetReturns(ctx, repository.ReturnsQuery{PickupPointID: "pvz2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ord2"}, ids(page))
+}
@@ -0,0 +1,82 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+// RunRecipientRepository runs the recipient suite. newRepo must return an
+// empty repository for every call.
+func RunRecipientRepository(t *testing.T, newRepo func(t *testing.T) repository.RecipientRepository) {
+	tests := map[string]func(t *testing.T, repo repository.RecipientRepository){
+		"CRUD":   testRecipientCRUD,
+		"Ensure": testEnsureRecipient,
+		"List":   testListRecipients,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepo(t))
+		})
+	}
+}
+
+func newRecipient(id string) *models.Recipient {
+	return &models.Recipient{ID: id, Name: "Ann", Phone: "+79990001122", Status: models.RecipientActive}
+}
+
+func testRecipientCRUD(t *testing.T, repo repository.RecipientRepository) {
+	ctx := context.Background()
+	rc := newRecipient("user1")
+	require.NoError(t, repo.CreateRecipient(ctx, rc))
+	assert.False(t, rc.CreatedAt.IsZero())
+	assert.ErrorIs(t, repo.CreateRecipient(ctx, newRecipient("user1")), repository.ErrRecipientExists)
+
+	rc.Status = models.RecipientBlocked
+	require.NoError(t, repo.UpdateRecipient(ctx, rc))
+	got, err := repo.GetRecipient(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, models.RecipientBlocked, got.Status)
+	assert.Equal(t, "Ann", got.Name)
+
+	require.NoError(t, repo.DeleteRecipient(ctx, "user1"))
+	_, err = repo.GetRecipient(ctx, "user1")
+	assert.ErrorIs(t, err, repository.ErrRecipientNotFound)
+	assert.ErrorIs(t, repo.DeleteRecipient(ctx, "user1"), repository.ErrRecipientNotFound)
+	assert.ErrorIs(t, repo.UpdateRecipient(ctx, newRecipient("ghost")), repository.ErrRecipientNotFound)
+}
+
+func testEnsureRecipient(t *testing.T, repo repository.RecipientRepository) {
+	ctx := context.Background()
+	rc, err := repo.EnsureRecipient(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, models.RecipientActive, rc.Status)
+
+	blocked := newRecipient("user2")
+	blocked.Status = models.RecipientBlocked
+	require.NoError(t, repo.CreateRecipient(ctx, blocked))
+	rc, err = repo.EnsureRecipient(ctx, "user2")
+	require.NoError(t, err)
+	assert.Equal(t, models.RecipientBlocked, rc.Status)
+}
+
+func testListRecipients(t *testing.T, repo repository.RecipientRepository) {
+	ctx := context.Background()
+	for _, id := range []string{"c", "a", "b"} {
+		require.NoError(t, repo.CreateRecipient(ctx, newRecipient(id)))
+	}
+	page, err := repo.ListRecipients(ctx, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, []string{"a", "b"}, []string{page[0].ID, page[1].ID})
+
+	page, err = repo.ListRecipients(ctx, "b", 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "c", page[0].ID)
+}
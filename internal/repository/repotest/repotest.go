@@ -0,0 +1,589 @@
+// Package repotest holds a conformance suite shared by every implementation
+// of repository.Repository, repository.TaskRepository,
+// repository.RecipientRepository and repository.PickupPointRepository.
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/auth"
+	"homework/internal/models"
+	"homework/internal/packaging"
+	"homework/internal/repository"
+)
+
+// RunOrderRepository runs the order suite. newRepo must return an empty
+// repository for every call.
+func RunOrderRepository(t *testing.T, newRepo func(t *testing.T) repository.Repository) {
+	tests := map[string]func(t *testing.T, repo repository.Repository){
+		"CreateAndGet":       testCreateAndGet,
+		"CreateDuplicate":    testCreateDuplicate,
+		"CreateBadPackaging": testCreateBadPackaging,
+		"CreateBatch":        testCreateBatch,
+		"NotFound":           testNotFound,
+		"UpdateTx":           testUpdateTx,
+		"UpdateIfVersion":    testUpdateIfVersion,
+		"Transitions":        testTransitions,
+		"ListCursor":         testListCursor,
+		"Export":             testExport,
+		"Search":             testSearch,
+		"GetReturnsOffset":   testGetReturnsOffset,
+		"GetReturnsKeyset":   testGetReturnsKeyset,
+		"ConcurrentDelivery": testConcurrentDelivery,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepo(t))
+		})
+	}
+}
+
+// RunTaskRepository runs the task suite. newRepo must return an empty
+// repository for every call.
+func RunTaskRepository(t *testing.T, newRepo func(t *testing.T) repository.TaskRepository) {
+	tests := map[string]func(t *testing.T, repo repository.TaskRepository){
+		"ClaimPending":       testClaimPending,
+		"FailureRetry":       testFailureRetry,
+		"ConcurrentClaiming": testConcurrentClaiming,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepo(t))
+		})
+	}
+}
+
+func newOrder(id, recipientID string) *models.Order {
+	return &models.Order{
+		ID:              id,
+		RecipientID:     recipientID,
+		StorageDeadline: time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second),
+		LastStateChange: time.Now().UTC(),
+		Weight:          2,
+		Cost:            100,
+		Packaging:       packaging.Packaging{"box+film"},
+	}
+}
+
+func ids(orders []*models.Order) []string {
+	result := make([]string, 0, len(orders))
+	for _, o := range orders {
+		result = append(result, o.ID)
+	}
+	return result
+}
+
+func testCreateAndGet(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	o := newOrder("ord1", "user1")
+	require.NoError(t, repo.Create(ctx, o))
+
+	got, err := repo.GetID(ctx, "ord1")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "user1", got.RecipientID)
+	assert.True(t, o.StorageDeadline.Equal(got.StorageDeadline))
+	assert.True(t, got.AcceptedAt.IsZero())
+	assert.Equal(t, models.OrderStateNew, got.CurrentState())
+	assert.ElementsMatch(t, []string{"box", "film"}, got.Packaging)
+	assert.Equal(t, 121.0, got.FinalCost)
+
+	pkgs, err := repo.FetchPackaging(ctx, "ord1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"box", "film"}, pkgs)
+}
+
+func testCreateDuplicate(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, newOrder("ord1", "user1")))
+	assert.ErrorIs(t, repo.Create(ctx, newOrder("ord1", "user2")), repository.ErrOrderExists)
+
+	got, err := repo.GetID(ctx, "ord1")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", got.RecipientID)
+}
+
+func testCreateBatch(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, newOrder("ord1", "user1")))
+
+	existing, err := repo.CreateBatch(ctx, []*models.Order{newOrder("ord2", "user1"), newOrder("ord1", "user2")}, true)
+	assert.ErrorIs(t, err, repository.ErrOrderExists)
+	assert.Equal(t, []string{"ord1"}, existing)
+	got, err := repo.GetID(ctx, "ord2")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	batch := []*models.Order{newOrder("ord2", "user1"), newOrder("ord1", "user2"), newOrder("ord3", "user3"), newOrder("ord3", "user4")}
+	batch[0].Packaging = packaging.Packaging{"box"}
+	existing, err = repo.CreateBatch(ctx, batch, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ord1", "ord3"}, existing)
+
+	got, err = repo.GetID(ctx, "ord2")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, []string{"box"}, []string(got.Packaging))
+	assert.Equal(t, int64(1), got.Version)
+	got, err = repo.GetID(ctx, "ord3")
+	require.NoError(t, err)
+	assert.Equal(t, "user3", got.RecipientID)
+
+	events, err := repo.History(ctx, "ord2")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.OrderEventCreated, events[0].Type)
+}
+
+func testCreateBadPackaging(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	o := newOrder("ord1", "user1")
+	o.Packaging = packaging.Packaging{"box+bag"}
+	assert.ErrorIs(t, repo.Create(ctx, o), packaging.ErrInvalidPackaging)
+
+	got, err := repo.GetID(ctx, "ord1")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func testNotFound(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	got, err := repo.GetID(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	assert.ErrorIs(t, repo.Delete(ctx, "missing"), repository.ErrOrderNotFound)
+	assert.ErrorIs(t, repo.UpdateTx(ctx, newOrder("missing", "user1")), repository.ErrOrderNotFound)
+	assert.ErrorIs(t, repo.AcceptOrder(ctx, "missing"), repository.ErrOrderNotFound)
+	assert.ErrorIs(t, repo.Deliver(ctx, "missing"), repository.ErrOrderNotFound)
+	assert.ErrorIs(t, repo.ClientReturn(ctx, "missing"), repository.ErrOrderNotFound)
+	assert.ErrorIs(t, repo.ReturnOrder(ctx, "missing"), repository.ErrOrderNotFound)
+}
+
+func testUpdateTx(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, newOrder("ord1", "user1")))
+
+	o := newOrder("ord1", "user2")
+	o.Packaging = packaging.Packaging{"bag"}
+	require.NoError(t, repo.UpdateTx(ctx, o))
+
+	got, err := repo.GetID(ctx, "ord1")
+	require.NoError(t, err)
+	assert.Equal(t, "user2", got.RecipientID)
+	assert.Equal(t, []string{"bag"}, []string(got.Packaging))
+	assert.Equal(t, 105.0, got.FinalCost)
+
+	o.DeliveredAt = time.Now().UTC()
+	assert.ErrorIs(t, repo.UpdateTx(ctx, o), models.ErrInvalidTransition)
+}
+
+func testUpdateIfVersion(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	o := newOrder("ord1", "user1")
+	require.NoError(t, repo.Create(ctx, o))
+	assert.Equal(t, int64(1), o.Version)
+
+	require.NoError(t, repo.UpdateIfVersion(ctx, newOrder("ord1", "user2"), 1))
+	got, err := repo.GetID(ctx, "ord1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), got.Version)
+
+	assert.ErrorIs(t, repo.UpdateIfVersion(ctx, newOrder("ord1", "user3"), 1), repository.ErrVersionMismatch)
+	assert.ErrorIs(t, repo.UpdateIfVersion(ctx, newOrder("missing", "user3"), 1), repository.ErrOrderNotFound)
+
+	require.NoError(t, repo.AcceptOrder(ctx, "ord1"))
+	got, err = repo.GetID(ctx, "ord1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), got.Version)
+}
+
+func testTransitions(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, newOrder("ord1", "user1")))
+
+	assert.ErrorIs(t, repo.Deliver(ctx, "ord1"), models.ErrInvalidTransition)
+	require.NoError(t, repo.AcceptOrder(ctx, "ord1"))
+	assert.ErrorIs(t, repo.AcceptOrder(ctx, "ord1"), models.ErrAlreadyInState)
+	assert.ErrorIs(t, repo.ClientReturn(ctx, "ord1"), models.ErrInvalidTransition)
+	require.NoError(t, repo.Deliver(ctx, "ord1"))
+	assert.ErrorIs(t, repo.ReturnOrder(ctx, "ord1"), models.ErrInvalidTransition)
+	require.NoError(t, repo.ClientReturn(ctx, "ord1"))
+	require.NoError(t, repo.ReturnOrder(ctx, "ord1"))
+	assert.ErrorIs(t, repo.Deliver(ctx, "ord1"), models.ErrInvalidTransition)
+
+	got, err := repo.GetID(ctx, "ord1")
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStateReturned, got.CurrentState())
+	assert.False(t, got.AcceptedAt.IsZero())
+	assert.False(t, got.DeliveredAt.IsZero())
+	assert.False(t, got.ClientReturnAt.IsZero())
+}
+
+func testListCursor(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		recipient := "user1"
+		if i%2 == 0 {
+			recipient = "user2"
+		}
+		require.NoError(t, repo.Create(ctx, newOrder(fmt.Sprintf("ord%d", i), recipient)))
+	}
+
+	page, err := repo.List(ctx, "", 2, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ord1", "ord2"}, ids(page))
+
+	page, err = repo.List(ctx, "ord2", 2, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ord3", "ord4"}, ids(page))
+
+	page, err = repo.List(ctx, "ord4", 2, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ord5"}, ids(page))
+
+	page, err = repo.List(ctx, "", 0, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ord1", "ord3", "ord5"}, ids(page))
+}
+
+func testExport(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	for _, id := range []string{"ord3", "ord1", "ord2"} {
+		require.NoError(t, repo.Create(ctx, newOrder(id, "user1")))
+	}
+	require.NoError(t, repo.Create(ctx, newOrder("ord4", "user2")))
+	require.NoError(t, repo.AcceptOrder(ctx, "ord2"))
+	require.NoError(t, repo.AcceptOrder(ctx, "ord3"))
+
+	export := func(f repository.OrderFilter) []*models.Order {
+		var orders []*models.Order
+		require.NoError(t, repo.Export(ctx, f, func(o *models.Order) error {
+			orders = append(orders, o)
+			return nil
+		}))
+		return orders
+	}
+
+	all := export(repository.OrderFilter{})
+	assert.Equal(t, []string{"ord1", "ord2", "ord3", "ord4"}, ids(all))
+	assert.Equal(t, []string{"box", "film"}, []string(all[0].Packaging))
+
+	accepted := export(repository.OrderFilter{States: []models.OrderState{models.OrderStateAccepted}, RecipientID: "user1"})
+	assert.Equal(t, []string{"ord2", "ord3"}, ids(accepted))
+
+	fresh := export(repository.OrderFilter{States: []models.OrderState{models.OrderStateNew}})
+	assert.Equal(t, []string{"ord1", "ord4"}, ids(fresh))
+
+	future := export(repository.OrderFilter{StateChanged: repository.TimeRange{From: time.Now().Add(time.Hour)}})
+	assert.Empty(t, future)
+}
+
+func testSearch(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	costs := map[string]float64{"ord1": 300, "ord2": 100, "ord3": 200, "ord4": 200, "ord5": 50}
+	for id, cost := range costs {
+		o := newOrder(id, "user1")
+		o.Cost = cost
+		if id == "ord5" {
+			o.Packaging = packaging.Packaging{"bag"}
+		}
+		require.NoError(t, repo.Create(ctx, o))
+	}
+	require.NoError(t, repo.AcceptOrder(ctx, "ord3"))
+
+	byCost := repository.OrderSort{Field: repository.SortByCost, Desc: true}
+	var (
+		seen  []string
+		after *repository.Keyset
+	)
+	for {
+		page, err := repo.Search(ctx, repository.OrderQuery{
+			Filter: repository.OrderFilter{Cost: repository.FloatRange{Min: 100}},
+			Sort:   byCost,
+			After:  after,
+			Limit:  2,
+		})
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		seen = append(seen, ids(page)...)
+		last := page[len(page)-1]
+		after = &repository.Keyset{Key: byCost.Field.Key(last), ID: last.ID}
+	}
+	assert.Equal(t, []string{"ord1", "ord4", "ord3", "ord2"}, seen)
+
+	page, err := repo.Search(ctx, repository.OrderQuery{Filter: repository.OrderFilter{Packaging: "film"}, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ord1", "ord2", "ord3", "ord4"}, ids(page))
+	assert.Equal(t, []string{"box", "film"}, []string(page[0].Packaging))
+
+	page, err = repo.Search(ctx, repository.OrderQuery{
+		Filter: repository.OrderFilter{Accepted: repository.TimeRange{From: time.Now().Add(-time.Hour)}},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ord3"}, ids(page))
+}
+
+func createReturns(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	for i := 1; i <= 4; i++ {
+		id := fmt.Sprintf("rtn%d", i)
+		require.NoError(t, repo.Create(ctx, newOrder(id, fmt.Sprintf("user%d", i%2))))
+		require.NoError(t, repo.AcceptOrder(ctx, id))
+		require.NoError(t, repo.Deliver(ctx, id))
+		require.NoError(t, repo.ClientReturn(ctx, id))
+	}
+	require.NoError(t, repo.Create(ctx, newOrder("kept", "user1")))
+}
+
+func testGetReturnsOffset(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	createReturns(t, repo)
+
+	page, err := repo.GetReturns(ctx, repository.ReturnsQuery{Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rtn1", "rtn2", "rtn3"}, ids(page))
+
+	page, err = repo.GetReturns(ctx, repository.ReturnsQuery{Offset: 3, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rtn4"}, ids(page))
+
+	page, err = repo.GetReturns(ctx, repository.ReturnsQuery{RecipientID: "user1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rtn1", "rtn3"}, ids(page))
+}
+
+func testGetReturnsKeyset(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	createReturns(t, repo)
+
+	page, err := repo.GetReturns(ctx, repository.ReturnsQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"rtn1", "rtn2"}, ids(page))
+
+	last := page[len(page)-1]
+	after := &repository.Keyset{Key: repository.ReturnKey(last), ID: last.ID}
+	page, err = repo.GetReturns(ctx, repository.ReturnsQuery{After: after, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rtn3", "rtn4"}, ids(page))
+
+	page, err = repo.GetReturns(ctx, repository.ReturnsQuery{RecipientID: "user0", After: after})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rtn4"}, ids(page))
+}
+
+func testConcurrentDelivery(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, newOrder("ord1", "user1")))
+	require.NoError(t, repo.AcceptOrder(ctx, "ord1"))
+
+	const workers = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.Deliver(ctx, "ord1"); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, succeeded)
+}
+
+func testExpireOverdue(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour).UTC()
+
+	create := func(id string, deadline time.Time, states ...func(context.Context, string) error) {
+		o := newOrder(id, "user1")
+		o.StorageDeadline = deadline
+		require.NoError(t, repo.Create(ctx, o))
+		for _, apply := range states {
+			require.NoError(t, apply(ctx, id))
+		}
+	}
+	create("overdue1", past.Add(-time.Minute), repo.AcceptOrder)
+	create("overdue2", past, repo.AcceptOrder)
+	create("new", past)
+	create("delivered", past, repo.AcceptOrder, repo.Deliver)
+	create("future", time.Now().Add(time.Hour), repo.AcceptOrder)
+
+	expired, err := repo.ExpireOverdue(ctx, time.Now().UTC(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"overdue1"}, ids(expired))
+	assert.Equal(t, models.OrderStateReturned, expired[0].CurrentState())
+	assert.ElementsMatch(t, []string{"box", "film"}, expired[0].Packaging)
+
+	expired, err = repo.ExpireOverdue(ctx, time.Now().UTC(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"overdue2"}, ids(expired))
+
+	got, err := repo.GetID(ctx, "overdue1")
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStateReturned, got.CurrentState())
+	assert.ElementsMatch(t, []string{"box", "film"}, got.Packaging)
+
+	for id, state := range map[string]models.OrderState{
+		"new":       models.OrderStateNew,
+		"delivered": models.OrderStateDelivered,
+		"future":    models.OrderStateAccepted,
+	} {
+		got, err := repo.GetID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, state, got.CurrentState(), id)
+	}
+}
+
+func testHistory(t *testing.T, repo repository.Repository) {
+	ctx := auth.WithActor(context.Background(), "clerk1")
+
+	_, err := repo.History(ctx, "missing")
+	assert.ErrorIs(t, err, repository.ErrOrderNotFound)
+
+	require.NoError(t, repo.Create(ctx, newOrder("ord1", "user1")))
+	require.NoError(t, repo.AcceptOrder(ctx, "ord1"))
+	assert.Error(t, repo.ClientReturn(ctx, "ord1"))
+
+	edited := newOrder("ord1", "user2")
+	got, err := repo.GetID(ctx, "ord1")
+	require.NoError(t, err)
+	edited.StorageDeadline = got.StorageDeadline
+	edited.AcceptedAt = got.AcceptedAt
+	require.NoError(t, repo.UpdateTx(ctx, edited))
+	require.NoError(t, repo.Delete(ctx, "ord1"))
+
+	events, err := repo.History(ctx, "ord1")
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	assert.Equal(t, models.OrderEventCreated, events[0].Type)
+	assert.Equal(t, models.OrderStateNew, events[0].NewState)
+
+	assert.Equal(t, models.OrderEventStateChanged, events[1].Type)
+	assert.Equal(t, models.OrderStateNew, events[1].OldState)
+	assert.Equal(t, models.OrderStateAccepted, events[1].NewState)
+	require.Len(t, events[1].Changes, 1)
+	assert.Equal(t, "accepted_at", events[1].Changes[0].Field)
+	assert.JSONEq(t, "null", string(events[1].Changes[0].Old))
+
+	assert.Equal(t, models.OrderEventUpdated, events[2].Type)
+	require.Len(t, events[2].Changes, 1)
+	assert.Equal(t, "recipient_id", events[2].Changes[0].Field)
+	assert.JSONEq(t, `"user1"`, string(events[2].Changes[0].Old))
+	assert.JSONEq(t, `"user2"`, string(events[2].Changes[0].New))
+
+	assert.Equal(t, models.OrderEventDeleted, events[3].Type)
+	assert.Equal(t, models.OrderStateAccepted, events[3].OldState)
+
+	for _, e := range events {
+		assert.Equal(t, "ord1", e.OrderID)
+		assert.Equal(t, "clerk1", e.Actor)
+	}
+}
+
+func testClaimPending(t *testing.T, repo repository.TaskRepository) {
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, repo.CreateTask(ctx, []byte(fmt.Sprintf(`{"n": %d}`, i))))
+	}
+
+	tasks, err := repo.GetPendingTasks(ctx, 2, 3, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	for _, task := range tasks {
+		assert.Equal(t, repository.TaskStatusProcessing, task.Status)
+		assert.Equal(t, 1, task.AttemptCount)
+	}
+
+	rest, err := repo.GetPendingTasks(ctx, 10, 3, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+
+	for _, task := range append(tasks, rest...) {
+		require.NoError(t, repo.DeleteTask(ctx, task.ID))
+	}
+	none, err := repo.GetPendingTasks(ctx, 10, 3, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func testFailureRetry(t *testing.T, repo repository.TaskRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.CreateTask(ctx, []byte(`{}`)))
+
+	tasks, err := repo.GetPendingTasks(ctx, 10, 2, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	task := tasks[0]
+
+	require.NoError(t, repo.UpdateTaskFailure(ctx, task.ID, 1, repository.TaskStatusFailed, time.Now().Add(time.Hour)))
+	notDue, err := repo.GetPendingTasks(ctx, 10, 2, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, notDue)
+
+	require.NoError(t, repo.UpdateTaskFailure(ctx, task.ID, 1, repository.TaskStatusFailed, time.Now().Add(-time.Second)))
+	retried, err := repo.GetPendingTasks(ctx, 10, 2, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, retried, 1)
+	assert.Equal(t, 2, retried[0].AttemptCount)
+
+	require.NoError(t, repo.UpdateTaskFailure(ctx, task.ID, 2, repository.TaskStatusFailed, time.Now().Add(-time.Second)))
+	exhausted, err := repo.GetPendingTasks(ctx, 10, 2, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, exhausted)
+}
+
+func testConcurrentClaiming(t *testing.T, repo repository.TaskRepository) {
+	ctx := context.Background()
+	const total = 40
+	for i := 0; i < total; i++ {
+		require.NoError(t, repo.CreateTask(ctx, []byte(`{}`)))
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		claimed = make(map[int]int)
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				tasks, err := repo.GetPendingTasks(ctx, 3, 5, time.Minute)
+				if err != nil || len(tasks) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, task := range tasks {
+					claimed[task.ID]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, claimed, total)
+	for id, n := range claimed {
+		assert.Equal(t, 1, n, "task %d claimed %d times", id, n)
+	}
+}
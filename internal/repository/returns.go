@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"homework/internal/models"
+)
+
+// ReturnsQuery is one page of client returns, ordered by (client_return_at,
+// id). After, when set, continues behind that position; its Key is formatted
+// by ReturnKey.
+type ReturnsQuery struct {
+	RecipientID   string
+	PickupPointID string
+	After         *Keyset
+	// Offset skips rows from the start of the listing instead of using After.
+	//
+	// Deprecated: deep offsets are slow and shift as returns arrive.
+	Offset int64
+	Limit  int64
+}
+
+// ReturnKey formats the position of a returned order for Keyset.Key.
+func ReturnKey(o *models.Order) string {
+	return o.ClientReturnAt.UTC().Format(time.RFC3339Nano)
+}
+
+// CompareReturns orders returned orders by (client_return_at, id).
+func CompareReturns(a, b *models.Order) int {
+	if c := a.ClientReturnAt.Compare(b.ClientReturnAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// Match reports whether o is a client return on the page of q, ignoring
+// Offset and Limit. A keyset that does not parse matches nothing.
+func (q ReturnsQuery) Match(o *models.Order) bool {
+	if o.ClientReturnAt.IsZero() || (q.RecipientID != "" && o.RecipientID != q.RecipientID) {
+		return false
+	}
+	if q.PickupPointID != "" && o.PickupPointID != q.PickupPointID {
+		return false
+	}
+	if q.After == nil {
+		return true
+	}
+	at, err := time.Parse(time.RFC3339Nano, q.After.Key)
+	if err != nil {
+		return false
+	}
+	return CompareReturns(o, &models.Order{ID: q.After.ID, ClientReturnAt: at}) > 0
+}
+
+func (r *OrderRepository) GetReturns(ctx context.Context, q ReturnsQuery) ([]*models.Order, error) {
+	if q.Limit <= 0 {
+		q.Limit = 10
+	}
+	var b queryBuilder
+	b.where("client_return_at IS NOT NULL")
+	if q.RecipientID != "" {
+		b.where("recipient_id = ?", q.RecipientID)
+	}
+	if q.PickupPointID != "" {
+		b.where("pickup_point_id = ?", q.PickupPointID)
+	}
+	if q.After != nil {
+		at, err := time.Parse(time.RFC3339Nano, q.After.Key)
+		if err != nil {
+			return nil, fmt.Errorf("GetReturns: keyset: %w", err)
+		}
+		b.where("(client_return_at, id) > (?, ?)", at, q.After.ID)
+	}
+	query := `SELECT ` + orderColumns + ` FROM orders` + b.whereClause() +
+		` ORDER BY client_return_at, id LIMIT ` + b.arg(q.Limit)
+	if q.Offset > 0 {
+		query += ` OFFSET ` + b.arg(q.Offset)
+	}
+
+	orders, err := r.queryOrders(ctx, r.db, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetReturns: %w", err)
+	}
+	return orders, nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework/internal/models"
+)
+
+// SortField is a column orders can be listed by. Only NOT NULL columns are
+// sortable, which keeps keyset pagination simple.
+type SortField string
+
+const (
+	SortByID           SortField = "id"
+	SortByDeadline     SortField = "storage_deadline"
+	SortByStateChanged SortField = "last_state_change"
+	SortByWeight       SortField = "weight"
+	SortByCost         SortField = "cost"
+)
+
+// SortFields lists the valid sort fields.
+var SortFields = []SortField{SortByID, SortByDeadline, SortByStateChanged, SortByWeight, SortByCost}
+
+// OrderSort orders a listing by Field, then by id to break ties.
+type OrderSort struct {
+	Field SortField
+	Desc  bool
+}
+
+// Keyset is the position of the last order of a page: its sort key,
+// formatted by SortField.Key, and its id.
+type Keyset struct {
+	Key string
+	ID  string
+}
+
+// OrderQuery is one page of a filtered, sorted listing. After, when set,
+// continues the listing behind that position.
+type OrderQuery struct {
+	Filter OrderFilter
+	Sort   OrderSort
+	After  *Keyset
+	Limit  int
+}
+
+// Key formats the sort key of o so that ParseKey can read it back.
+func (f SortField) Key(o *models.Order) string {
+	switch f {
+	case SortByDeadline:
+		return o.StorageDeadline.UTC().Format(time.RFC3339Nano)
+	case SortByStateChanged:
+		return o.LastStateChange.UTC().Format(time.RFC3339Nano)
+	case SortByWeight:
+		return strconv.FormatFloat(o.Weight, 'g', -1, 64)
+	case SortByCost:
+		return strconv.FormatFloat(o.Cost, 'g', -1, 64)
+	default:
+		return o.ID
+	}
+}
+
+// ParseKey turns a Key back into a value of the column's type.
+func (f SortField) ParseKey(key string) (any, error) {
+	switch f {
+	case SortByDeadline, SortByStateChanged:
+		return time.Parse(time.RFC3339Nano, key)
+	case SortByWeight, SortByCost:
+		return strconv.ParseFloat(key, 64)
+	default:
+		return key, nil
+	}
+}
+
+// Compare orders a and b by the sort, ties broken by id.
+func (s OrderSort) Compare(a, b *models.Order) int {
+	c := s.compareField(a, b)
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if s.Desc {
+		return -c
+	}
+	return c
+}
+
+// After reports whether o comes after the keyset position in sort order. A
+// key that does not parse places nothing after it.
+func (s OrderSort) After(o *models.Order, k Keyset) bool {
+	at := &models.Order{ID: k.ID}
+	key, err := s.Field.ParseKey(k.Key)
+	if err != nil {
+		return false
+	}
+	switch v := key.(type) {
+	case time.Time:
+		at.StorageDeadline, at.LastStateChange = v, v
+	case float64:
+		at.Weight, at.Cost = v, v
+	}
+	return s.Compare(o, at) > 0
+}
+
+func (s OrderSort) compareField(a, b *models.Order) int {
+	switch s.Field {
+	case SortByDeadline:
+		return a.StorageDeadline.Compare(b.StorageDeadline)
+	case SortByStateChanged:
+		return a.LastStateChange.Compare(b.LastStateChange)
+	case SortByWeight:
+		return cmp.Compare(a.Weight, b.Weight)
+	case SortByCost:
+		return cmp.Compare(a.Cost, b.Cost)
+	default:
+		return 0
+	}
+}
+
+func (s OrderSort) column() string {
+	if s.Field == "" {
+		return string(SortByID)
+	}
+	return string(s.Field)
+}
+
+// Search returns one page of orders matching q, using keyset pagination on
+// (sort column, id).
+func (r *OrderRepository) Search(ctx context.Context, q OrderQuery) ([]*models.Order, error) {
+	var b queryBuilder
+	q.Filter.apply(&b)
+	column, dir, op := q.Sort.column(), "ASC", ">"
+	if q.Sort.Desc {
+		dir, op = "DESC", "<"
+	}
+	if q.After != nil {
+		if err := applyKeyset(&b, q.Sort, column, op, *q.After); err != nil {
+			return nil, err
+		}
+	}
+	order := column + " " + dir
+	if column != string(SortByID) {
+		order += ", id " + dir
+	}
+	query := `SELECT ` + orderColumns + ` FROM orders` + b.whereClause() +
+		` ORDER BY ` + order + ` LIMIT ` + b.arg(q.Limit)
+
+	orders, err := r.queryOrders(ctx, r.db, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("search orders: %w", err)
+	}
+	return orders, nil
+}
+
+func applyKeyset(b *queryBuilder, s OrderSort, column, op string, after Keyset) error {
+	if column == string(SortByID) {
+		b.where("id "+op+" ?", after.ID)
+		return nil
+	}
+	key, err := s.Field.ParseKey(after.Key)
+	if err != nil {
+		return fmt.Errorf("search orders: keyset: %w", err)
+	}
+	b.where("("+column+", id) "+op+" (?, ?)", key, after.ID)
+	return nil
+}
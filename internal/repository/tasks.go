@@ -58,15 +58,15 @@ WITH updated AS (
     SET status = 'PROCESSING',
         attempt_count = attempt_count + 1,
         updated_at = NOW(),
-        next_attempt_at = NOW() + $2
+        next_attempt_at = NOW() + make_interval(secs => $1)
     WHERE id IN (
         SELECT id
         FROM tasks
-        WHERE status IN ($3, $4)
-          AND COALESCE(next_attempt_at, '-infinity'::timestamp) <= NOW()
-          AND attempt_count < $5
-        ORDER BY created_at
-        LIMIT $6
+        WHERE status IN ($2, $3)
+          AND COALESCE(next_attempt_at, '-infinity'::timestamptz) <= NOW()
+          AND attempt_count < $4
+        ORDER BY created_at, id
+        LIMIT $5
         FOR UPDATE SKIP LOCKED
     )
     RETURNING id, created_at, updated_at, finished_at, audit_data, status, attempt_count, next_attempt_at
@@ -74,8 +74,7 @@ WITH updated AS (
 SELECT * FROM updated;
 `
 	rows, err := r.db.QueryContext(ctx, query,
-		retryDelay,
-		retryDelay,
+		retryDelay.Seconds(),
 		TaskStatusCreated,
 		TaskStatusFailed,
 		maxAttempts,
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresRevokedTokenRepository is the denylist of bearer tokens revoked
+// before their expiry.
+type PostgresRevokedTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresRevokedTokenRepository(db *sql.DB) *PostgresRevokedTokenRepository {
+	return &PostgresRevokedTokenRepository{db: db}
+}
+
+// Revoke adds the token id to the denylist. Entries whose token has expired
+// anyway are pruned on the way.
+func (r *PostgresRevokedTokenRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("Revoke: %w", err)
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+	ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("Revoke: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRevokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("IsRevoked: %w", err)
+	}
+	return revoked, nil
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework/internal/apperr"
+	"homework/internal/auth"
+)
+
+type mintAPIKeyRequest struct {
+	Name      string            `json:"name"`
+	Scopes    []auth.Permission `json:"scopes"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// apiKeyResponse carries the plaintext secret, which is shown only when a
+// key is minted or rotated.
+type apiKeyResponse struct {
+	Key    *auth.APIKey `json:"key"`
+	Secret string       `json:"secret"`
+}
+
+func (s *Server) registerAPIKeyRoutes(mux *http.ServeMux) {
+	s.handleWith(mux, "/admin/api-keys", s.handleAPIKeys, auth.MethodPermissions{
+		http.MethodGet:  auth.PermManageKeys,
+		http.MethodPost: auth.PermManageKeys,
+	})
+	s.handleWith(mux, "/admin/api-keys/", s.handleAPIKeyOne, auth.MethodPermissions{
+		http.MethodPost:   auth.PermManageKeys,
+		http.MethodDelete: auth.PermManageKeys,
+	})
+}
+
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := s.authn.APIKeys.List(r.Context())
+		if err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, keys)
+	case http.MethodPost:
+		s.handleMintAPIKey(w, r)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+func (s *Server) handleMintAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req mintAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.WriteProblem(w, r, errMalformedJSON)
+		return
+	}
+	if req.Name == "" {
+		apperr.WriteProblem(w, r, apperr.NewValidation(apperr.Field("name", "is required")))
+		return
+	}
+	key, secret, err := s.authn.APIKeys.Mint(r.Context(), req.Name, req.Scopes, req.ExpiresAt, auth.ActorFromContext(r.Context()))
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	writeSecret(w, http.StatusCreated, apiKeyResponse{Key: key, Secret: secret})
+}
+
+// handleAPIKeyOne serves POST /admin/api-keys/{id}/rotate and
+// DELETE /admin/api-keys/{id}.
+func (s *Server) handleAPIKeyOne(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/admin/api-keys/")
+	idStr, rotate := strings.CutSuffix(rest, "/rotate")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		apperr.WriteProblem(w, r, apperr.NewValidation(apperr.Field("id", "must be an integer")))
+		return
+	}
+	switch {
+	case rotate && r.Method == http.MethodPost:
+		key, secret, err := s.authn.APIKeys.Rotate(r.Context(), id)
+		if err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		writeSecret(w, http.StatusOK, apiKeyResponse{Key: key, Secret: secret})
+	case !rotate && r.Method == http.MethodDelete:
+		if err := s.authn.APIKeys.Revoke(r.Context(), id); err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
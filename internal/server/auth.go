@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"homework/internal/apperr"
+	"homework/internal/auth"
+	"homework/internal/middleware"
+)
+
+type tokenRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type tokenResponse struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (s *Server) registerAuthRoutes(mux *http.ServeMux) {
+	mux.Handle("/auth/token", middleware.MetricsMiddleware("/auth/token")(
+		middleware.AuthFailureLimit("/auth/token", s.authFailures)(
+			middleware.RateLimitMiddleware("/auth/token", s.rateLimits.For("/auth/token"))(
+				middleware.AuditResponseMiddleware(s.auditPool)(http.HandlerFunc(s.handleIssueToken)),
+			),
+		),
+	))
+	mux.Handle("/auth/revoke", middleware.MetricsMiddleware("/auth/revoke")(
+		middleware.AuthFailureLimit("/auth/revoke", s.authFailures)(
+			middleware.BearerAuthenticate(s.authn.Tokens)(
+				middleware.AuditResponseMiddleware(s.auditPool)(http.HandlerFunc(s.handleRevokeToken)),
+			),
+		),
+	))
+}
+
+// handleIssueToken exchanges a username and password for a bearer token.
+func (s *Server) handleIssueToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+	var req tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.WriteProblem(w, r, errMalformedJSON)
+		return
+	}
+	principal, err := s.authn.Users.Authenticate(req.Username, req.Password)
+	if err != nil {
+		apperr.WriteStatus(w, r, http.StatusUnauthorized, "unauthorized", "unauthorized")
+		return
+	}
+	token, expiresAt, err := s.authn.Tokens.Issue(principal)
+	if err != nil {
+		apperr.WriteProblem(w, r, fmt.Errorf("issue token for %s: %w", principal.Name, err))
+		return
+	}
+	writeSecret(w, http.StatusOK, tokenResponse{AccessToken: token, TokenType: "Bearer", ExpiresAt: expiresAt})
+}
+
+// handleRevokeToken puts the bearer token of the request into the denylist.
+func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+		apperr.WriteStatus(w, r, http.StatusUnauthorized, "unauthorized", "unauthorized")
+		return
+	}
+	if err := s.authn.Tokens.Revoke(r.Context(), claims); err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
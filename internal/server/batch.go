@@ -0,0 +1,105 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"homework/internal/apperr"
+	"homework/internal/orderio"
+	"homework/internal/service"
+)
+
+const (
+	maxBatchBytes = 10 << 20
+	maxBatchRows  = 10000
+)
+
+var (
+	errEmptyBatch  = apperr.NewValidation(apperr.Field("rows", "batch is empty"))
+	errTooManyRows = apperr.NewValidation(apperr.Field("rows", "a batch holds at most %d orders", maxBatchRows))
+)
+
+// handleBatchOrders serves POST /orders:batch. The body is CSV or NDJSON,
+// chosen by Content-Type; ?mode=best_effort creates the valid rows even if
+// others fail, the default atomic mode creates all rows or none.
+func (s *Server) handleBatchOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+	mode, err := batchMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	read, ok := batchReader(r.Header.Get("Content-Type"))
+	if !ok {
+		apperr.WriteStatus(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type",
+			"Content-Type must be "+orderio.ContentTypeCSV+" or "+orderio.ContentTypeNDJSON)
+		return
+	}
+	rows, err := read(http.MaxBytesReader(w, r.Body, maxBatchBytes))
+	if err != nil {
+		writeBatchReadError(w, r, err)
+		return
+	}
+	switch {
+	case len(rows) == 0:
+		apperr.WriteProblem(w, r, errEmptyBatch)
+		return
+	case len(rows) > maxBatchRows:
+		apperr.WriteProblem(w, r, errTooManyRows)
+		return
+	}
+	report, err := s.wrap.ImportOrders(r.Context(), rows, mode)
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	writeJSON(w, batchStatus(report), report)
+}
+
+func batchMode(value string) (service.BatchMode, error) {
+	switch mode := service.BatchMode(value); mode {
+	case "":
+		return service.BatchAtomic, nil
+	case service.BatchAtomic, service.BatchBestEffort:
+		return mode, nil
+	}
+	return "", apperr.NewValidation(apperr.Field("mode", "must be %s or %s", service.BatchAtomic, service.BatchBestEffort))
+}
+
+func batchReader(contentType string) (func(io.Reader) ([]orderio.Row, error), bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case orderio.ContentTypeCSV:
+		return orderio.ReadCSV, true
+	case orderio.ContentTypeNDJSON, "application/ndjson":
+		return orderio.ReadNDJSON, true
+	}
+	return nil, false
+}
+
+func writeBatchReadError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		apperr.WriteStatus(w, r, http.StatusRequestEntityTooLarge, "body_too_large", "request body too large")
+		return
+	}
+	apperr.WriteProblem(w, r, err)
+}
+
+// batchStatus is 201 when every row was created, 422 when an atomic batch
+// was rejected and 200 for a best-effort batch with failures.
+func batchStatus(report *service.BatchReport) int {
+	switch {
+	case report.Failed == 0:
+		return http.StatusCreated
+	case report.Mode == service.BatchAtomic:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusOK
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+var (
+	errIfMatchRequired = errors.New("If-Match header required")
+	errIfMatchInvalid  = apperr.New(apperr.Validation, "invalid_if_match", "malformed If-Match header")
+)
+
+// etag renders an order version as a strong entity tag.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersion reads the order version a write to order id was based on
+// from the If-Match header. "*" matches any stored version and is reported
+// as 0. If-Match compares strongly, so weak tags never match; a list matches
+// if any of its tags names the stored version, which is then returned. A
+// header with no matching tag fails with repository.ErrVersionMismatch.
+func (s *Server) ifMatchVersion(r *http.Request, id string) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch value {
+	case "":
+		return 0, errIfMatchRequired
+	case "*":
+		return 0, nil
+	}
+	versions, err := parseIfMatch(value)
+	if err != nil {
+		return 0, err
+	}
+	switch len(versions) {
+	case 0:
+		return 0, fmt.Errorf("order %s: If-Match names no version: %w", id, repository.ErrVersionMismatch)
+	case 1:
+		return versions[0], nil
+	}
+	stored, err := s.wrap.OrderVersion(r.Context(), id)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, stored) {
+		return 0, fmt.Errorf("order %s: version %d: %w", id, stored, repository.ErrVersionMismatch)
+	}
+	return stored, nil
+}
+
+// parseIfMatch returns the order versions named by the strong entity tags
+// in a comma-separated If-Match list. Weak tags and tags that are not a
+// version are valid but can never match, so they are left out.
+func parseIfMatch(value string) ([]int64, error) {
+	var versions []int64
+	for value != "" {
+		opaque, weak, rest, err := nextETag(value)
+		if err != nil {
+			return nil, err
+		}
+		if version, err := strconv.ParseInt(opaque, 10, 64); !weak && err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+		value = rest
+	}
+	return versions, nil
+}
+
+// nextETag splits the first entity tag off a list and returns its opaque
+// part, whether it is weak, and the rest of the list after the comma.
+func nextETag(list string) (opaque string, weak bool, rest string, err error) {
+	rest, weak = strings.CutPrefix(list, "W/")
+	if !strings.HasPrefix(rest, `"`) {
+		return "", false, "", errIfMatchInvalid
+	}
+	opaque, rest, ok := strings.Cut(rest[1:], `"`)
+	if !ok {
+		return "", false, "", errIfMatchInvalid
+	}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return opaque, weak, "", nil
+	}
+	rest, ok = strings.CutPrefix(rest, ",")
+	if !ok {
+		return "", false, "", errIfMatchInvalid
+	}
+	return opaque, weak, strings.TrimSpace(rest), nil
+}
+
+// writeIfMatchError reports an ifMatchVersion error.
+func writeIfMatchError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errIfMatchRequired) {
+		apperr.WriteStatus(w, r, http.StatusPreconditionRequired, "if_match_required", err.Error())
+		return
+	}
+	apperr.WriteProblem(w, r, err)
+}
+
+// updateIfMatch writes o, guarded by version unless the client sent
+// "If-Match: *".
+func (s *Server) updateIfMatch(ctx context.Context, o *models.Order, version int64) error {
+	if version == 0 {
+		return s.wrap.UpdateOrder(ctx, o)
+	}
+	return s.wrap.UpdateOrderIfVersion(ctx, o, version)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+	"homework/internal/orderio"
+)
+
+// exportFlushRows is how many rows are buffered before they are pushed to
+// the client.
+const exportFlushRows = 500
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(io.Writer) orderio.Writer
+}
+
+var exportFormats = []exportFormat{
+	{orderio.ContentTypeCSV, "csv", orderio.NewCSVWriter},
+	{orderio.ContentTypeNDJSON, "ndjson", orderio.NewNDJSONWriter},
+}
+
+// negotiateExport picks the first format of the Accept header that is
+// supported. No header or */* selects CSV.
+func negotiateExport(accept string) (exportFormat, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return exportFormats[0], true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		if mediaType == "application/ndjson" {
+			mediaType = orderio.ContentTypeNDJSON
+		}
+		for _, f := range exportFormats {
+			if mediaType == f.contentType || mediaType == "*/*" || (mediaType == "text/*" && f.extension == "csv") {
+				return f, true
+			}
+		}
+	}
+	return exportFormat{}, false
+}
+
+// handleExportOrders serves GET /orders:export, streaming every matching order
+// from the database. The response starts with the first row, so an error
+// before it is still reported as a problem document; a later one cuts the
+// stream short.
+func (s *Server) handleExportOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+	format, ok := negotiateExport(r.Header.Get("Accept"))
+	if !ok {
+		apperr.WriteStatus(w, r, http.StatusNotAcceptable, "not_acceptable",
+			"Accept must allow "+orderio.ContentTypeCSV+" or "+orderio.ContentTypeNDJSON)
+		return
+	}
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+
+	stream := &exportStream{w: w, format: format, out: format.newWriter(w)}
+	if err := s.wrap.ExportOrders(r.Context(), filter, stream.write); err != nil {
+		if !stream.started {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		log.Printf("Error exporting orders after %d rows: %v", stream.rows, err)
+		return
+	}
+	stream.start()
+	if err := stream.out.Flush(); err != nil {
+		log.Printf("Error flushing order export: %v", err)
+	}
+}
+
+type exportStream struct {
+	w       http.ResponseWriter
+	format  exportFormat
+	out     orderio.Writer
+	started bool
+	rows    int
+}
+
+func (e *exportStream) start() {
+	if e.started {
+		return
+	}
+	e.started = true
+	e.w.Header().Set("Content-Type", e.format.contentType+"; charset=utf-8")
+	e.w.Header().Set("Content-Disposition", `attachment; filename="orders.`+e.format.extension+`"`)
+	e.w.WriteHeader(http.StatusOK)
+}
+
+func (e *exportStream) write(o *models.Order) error {
+	e.start()
+	if err := e.out.Write(o); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%exportFlushRows != 0 {
+		return nil
+	}
+	if err := e.out.Flush(); err != nil {
+		return err
+	}
+	// Not every writer in the middleware chain can flush; the data then
+	// goes out once the server's own buffer fills.
+	_ = http.NewResponseController(e.w).Flush()
+	return nil
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+var orderStates = map[string]models.OrderState{
+	"new":        models.OrderStateNew,
+	"accepted":   models.OrderStateAccepted,
+	"delivered":  models.OrderStateDelivered,
+	"client_rtn": models.OrderStateClientRtn,
+	"returned":   models.OrderStateReturned,
+}
+
+// filterParser collects the problems of every query parameter, so a client
+// sees all of them in one response.
+type filterParser struct {
+	q      url.Values
+	fields []apperr.FieldError
+}
+
+// parseOrderFilter reads repository.OrderFilter from the query: state
+// (comma-separated or repeated), recipient_id, pickup_point_id, packaging,
+// RFC 3339 ranges changed_, deadline_, accepted_ and delivered_from/_to, and
+// numeric ranges weight_ and cost_min/_max.
+func parseOrderFilter(q url.Values) (repository.OrderFilter, error) {
+	p := &filterParser{q: q}
+	f := repository.OrderFilter{
+		States:        p.states("state"),
+		RecipientID:   q.Get("recipient_id"),
+		PickupPointID: q.Get("pickup_point_id"),
+		StateChanged:  p.timeRange("changed"),
+		Deadline:      p.timeRange("deadline"),
+		Accepted:      p.timeRange("accepted"),
+		Delivered:     p.timeRange("delivered"),
+		Weight:        p.floatRange("weight"),
+		Cost:          p.floatRange("cost"),
+		Packaging:     q.Get("packaging"),
+	}
+	return f, p.err()
+}
+
+func (p *filterParser) timeRange(prefix string) repository.TimeRange {
+	return repository.TimeRange{
+		From: p.time(prefix + "_from"),
+		To:   p.time(prefix + "_to"),
+	}
+}
+
+func (p *filterParser) floatRange(prefix string) repository.FloatRange {
+	r := repository.FloatRange{
+		Min: p.float(prefix + "_min"),
+		Max: p.float(prefix + "_max"),
+	}
+	if r.Max != 0 && r.Min > r.Max {
+		p.fields = append(p.fields, apperr.Field(prefix+"_min", "must not exceed %s_max", prefix))
+	}
+	return r
+}
+
+func (p *filterParser) states(name string) []models.OrderState {
+	var states []models.OrderState
+	for _, value := range p.q[name] {
+		for _, s := range strings.Split(value, ",") {
+			state, ok := orderStates[strings.TrimSpace(s)]
+			if !ok {
+				p.fields = append(p.fields, apperr.Field(name, "unknown state %q", s))
+				continue
+			}
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+func (p *filterParser) time(name string) time.Time {
+	value := p.q.Get(name)
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		p.fields = append(p.fields, apperr.Field(name, "must be an RFC 3339 timestamp"))
+	}
+	return t
+}
+
+func (p *filterParser) float(name string) float64 {
+	value := p.q.Get(name)
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		p.fields = append(p.fields, apperr.Field(name, "must be a non-negative number"))
+	}
+	return f
+}
+
+func (p *filterParser) err() error {
+	if len(p.fields) > 0 {
+		return apperr.NewValidation(p.fields...)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"homework/internal/apperr"
+	"homework/internal/cursor"
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// pageParams position a page within a listing rather than shape it.
+var pageParams = map[string]bool{"cursor": true, "limit": true, "offset": true}
+
+// listCursor is the signed state behind next_cursor. Query fingerprints the
+// listing and the filters and sort it was issued for, so a cursor cannot be replayed against
+// a different listing.
+type listCursor struct {
+	Key   string `json:"k"`
+	ID    string `json:"id"`
+	Query string `json:"q"`
+}
+
+type listResponse struct {
+	Orders     []*models.Order `json:"orders"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// handleListOrders serves one page of GET /orders. Besides the filters of
+// parseOrderFilter it takes sort (a field, prefixed with "-" for descending
+// order), limit, and the cursor returned by the previous page.
+func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	q, err := s.parseOrderQuery(r.URL)
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	orders, more, err := s.wrap.SearchOrders(r.Context(), q)
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	resp := listResponse{Orders: orders}
+	if resp.Orders == nil {
+		resp.Orders = []*models.Order{}
+	}
+	if more {
+		last := orders[len(orders)-1]
+		resp.NextCursor, err = s.encodeCursor(q.Sort.Field.Key(last), last.ID, r.URL)
+		if err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) parseOrderQuery(u *url.URL) (repository.OrderQuery, error) {
+	values := u.Query()
+	filter, err := parseOrderFilter(values)
+	if err != nil {
+		return repository.OrderQuery{}, err
+	}
+	q := repository.OrderQuery{Filter: filter}
+	if q.Sort, err = parseOrderSort(values.Get("sort")); err != nil {
+		return q, err
+	}
+	if q.Limit, err = parseLimit(values.Get("limit"), defaultListLimit); err != nil {
+		return q, err
+	}
+	if token := values.Get("cursor"); token != "" {
+		q.After, err = s.decodeCursor(token, u)
+	}
+	return q, err
+}
+
+func parseOrderSort(value string) (repository.OrderSort, error) {
+	if value == "" {
+		return repository.OrderSort{Field: repository.SortByID}, nil
+	}
+	field, desc := strings.CutPrefix(value, "-")
+	sort := repository.OrderSort{Field: repository.SortField(field), Desc: desc}
+	if !slices.Contains(repository.SortFields, sort.Field) {
+		return sort, apperr.NewValidation(apperr.Field("sort", "unknown sort field %q", field))
+	}
+	return sort, nil
+}
+
+func parseLimit(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		return 0, apperr.NewValidation(apperr.Field("limit", "must be between 1 and %d", maxListLimit))
+	}
+	return limit, nil
+}
+
+// encodeCursor signs the position behind the last order of a page.
+func (s *Server) encodeCursor(key, id string, u *url.URL) (string, error) {
+	return s.cursors.Encode(listCursor{Key: key, ID: id, Query: queryFingerprint(u)})
+}
+
+// decodeCursor verifies a cursor and that it was issued for a listing with
+// the same path and parameters. The key needs no further checks: it was formatted by
+// the server for the sort the fingerprint pins down.
+func (s *Server) decodeCursor(token string, u *url.URL) (*repository.Keyset, error) {
+	var c listCursor
+	if err := s.cursors.Decode(token, &c); err != nil {
+		return nil, err
+	}
+	if c.Query != queryFingerprint(u) {
+		return nil, cursor.ErrInvalid
+	}
+	return &repository.Keyset{Key: c.Key, ID: c.ID}, nil
+}
+
+// queryFingerprint hashes the path and every parameter that shapes the
+// listing, that is all of them except the page position and size.
+func queryFingerprint(u *url.URL) string {
+	shape := url.Values{}
+	for name, v := range u.Query() {
+		if !pageParams[name] {
+			shape[name] = v
+		}
+	}
+	sum := sha256.Sum256([]byte(u.Path + "?" + shape.Encode()))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
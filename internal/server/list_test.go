@@ -0,0 +1,125 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/apperr"
+)
+
+type listPage struct {
+	Orders []struct {
+		ID string `json:"id"`
+	} `json:"orders"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// list fetches a page of GET /orders and returns its order IDs and cursor.
+func (ts *testServer) list(t *testing.T, target string) ([]string, string) {
+	t.Helper()
+	w := ts.do(http.MethodGet, target, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page listPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	ids := make([]string, 0, len(page.Orders))
+	for _, o := range page.Orders {
+		ids = append(ids, o.ID)
+	}
+	return ids, page.NextCursor
+}
+
+// newListServer returns a server with ord1 to ord5 of weights 1 to 5, the
+// even ones accepted.
+func newListServer(t *testing.T) *testServer {
+	ts := newTestServer(t)
+	for i := 1; i <= 5; i++ {
+		id := fmt.Sprintf("ord%d", i)
+		ts.createOrder(t, id, float64(i))
+		if i%2 == 0 {
+			require.Equal(t, http.StatusOK, ts.do(http.MethodPut, "/orders-accept/"+id, "").Code)
+		}
+	}
+	return ts
+}
+
+func TestListOrdersCursor(t *testing.T) {
+	ts := newListServer(t)
+
+	ids, next := ts.list(t, "/orders?sort=-weight&limit=2")
+	assert.Equal(t, []string{"ord5", "ord4"}, ids)
+	require.NotEmpty(t, next)
+
+	// Page position and size are not part of the listing.
+	ids, _ = ts.list(t, "/orders?sort=-weight&limit=3&offset=1&cursor="+next)
+	assert.Equal(t, []string{"ord3", "ord2", "ord1"}, ids)
+}
+
+func TestListOrdersCursorIsBoundToTheListing(t *testing.T) {
+	ts := newListServer(t)
+	_, next := ts.list(t, "/orders?sort=-weight&state=new,accepted&limit=2")
+	require.NotEmpty(t, next)
+
+	for name, target := range map[string]string{
+		"sort changed":    "/orders?sort=weight&state=new,accepted&limit=2&cursor=",
+		"filter changed":  "/orders?sort=-weight&state=new&limit=2&cursor=",
+		"filter added":    "/orders?sort=-weight&state=new,accepted&weight_min=1&limit=2&cursor=",
+		"filter dropped":  "/orders?sort=-weight&limit=2&cursor=",
+		"other listing":   "/returns?sort=-weight&state=new,accepted&limit=2&cursor=",
+		"tampered cursor": "/orders?sort=-weight&state=new,accepted&limit=2&cursor=x",
+	} {
+		t.Run(name, func(t *testing.T) {
+			assertProblem(t, ts.do(http.MethodGet, target+next, ""), http.StatusBadRequest, "invalid_cursor")
+		})
+	}
+}
+
+func TestListOrdersFilters(t *testing.T) {
+	ts := newListServer(t)
+
+	tests := map[string]struct {
+		query string
+		want  []string
+	}{
+		"comma-separated states": {"state=new,accepted", []string{"ord1", "ord2", "ord3", "ord4", "ord5"}},
+		"repeated state":         {"state=accepted&state=new&weight_max=2", []string{"ord1", "ord2"}},
+		"one state":              {"state=accepted", []string{"ord2", "ord4"}},
+		"weight range":           {"weight_min=2&weight_max=4&sort=-id", []string{"ord4", "ord3", "ord2"}},
+		"time range":             {"changed_from=2000-01-01T00:00:00Z&changed_to=2000-01-02T00:00:00Z", []string{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ids, _ := ts.list(t, "/orders?"+tt.query)
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestListOrdersInvalidQuery(t *testing.T) {
+	ts := newListServer(t)
+
+	w := ts.do(http.MethodGet, "/orders?state=lost&changed_from=yesterday&weight_min=5&weight_max=1&cost_min=-1", "")
+	assert.Equal(t, []apperr.FieldError{
+		apperr.Field("state", `unknown state "lost"`),
+		apperr.Field("changed_from", "must be an RFC 3339 timestamp"),
+		apperr.Field("weight_min", "must not exceed weight_max"),
+		apperr.Field("cost_min", "must be a non-negative number"),
+	}, problemFields(t, w))
+
+	assertProblem(t, ts.do(http.MethodGet, "/orders?sort=-color", ""), http.StatusBadRequest, "validation_failed")
+	assertProblem(t, ts.do(http.MethodGet, "/orders?limit=101", ""), http.StatusBadRequest, "validation_failed")
+}
+
+// problemFields returns the field errors of a 400 problem document.
+func problemFields(t *testing.T, w *httptest.ResponseRecorder) []apperr.FieldError {
+	t.Helper()
+	assertProblem(t, w, http.StatusBadRequest, "validation_failed")
+	var p apperr.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	return p.Errors
+}
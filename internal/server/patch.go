@@ -0,0 +1,45 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	maxPatchBytes         = 1 << 20
+)
+
+// handlePatchOrder applies an RFC 7386 merge patch to the editable fields of
+// an order. The read-modify-write is guarded by the version in If-Match, so a
+// concurrent write turns into 412 rather than being overwritten; with
+// "If-Match: *" the patch applies to the version stored at the time.
+func (s *Server) handlePatchOrder(w http.ResponseWriter, r *http.Request, id string) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != mergePatchContentType {
+		apperr.WriteStatus(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type", "Content-Type must be "+mergePatchContentType)
+		return
+	}
+	version, err := s.ifMatchVersion(r, id)
+	if err != nil {
+		writeIfMatchError(w, r, err)
+		return
+	}
+	doc, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchBytes))
+	if err != nil {
+		apperr.WriteStatus(w, r, http.StatusRequestEntityTooLarge, "body_too_large", "request body too large")
+		return
+	}
+	o, err := s.wrap.PatchOrder(r.Context(), id, version, func(o *models.Order) error {
+		return models.ApplyMergePatch(o, doc)
+	})
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(o.Version))
+	writeJSON(w, http.StatusOK, o)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"homework/internal/apperr"
+	"homework/internal/auth"
+	"homework/internal/models"
+)
+
+type pickupPointRequest struct {
+	ID           string `json:"id"`
+	Address      string `json:"address"`
+	WorkingHours string `json:"working_hours"`
+	Capacity     int    `json:"capacity"`
+}
+
+func (req pickupPointRequest) pickupPoint() *models.PickupPoint {
+	return &models.PickupPoint{ID: req.ID, Address: req.Address, WorkingHours: req.WorkingHours, Capacity: req.Capacity}
+}
+
+func (s *Server) registerPickupPointRoutes(mux *http.ServeMux) {
+	s.handleWith(mux, "/pickup-points", s.handlePickupPoints, auth.MethodPermissions{
+		http.MethodGet:  auth.PermRead,
+		http.MethodPost: auth.PermManagePickupPoints,
+	})
+	s.handleWith(mux, "/pickup-points/", s.handlePickupPointOne, auth.MethodPermissions{
+		http.MethodGet: auth.PermRead,
+		http.MethodPut: auth.PermManagePickupPoints,
+	})
+}
+
+// handlePickupPoints serves GET /pickup-points, every point with its current
+// occupancy, and POST /pickup-points.
+func (s *Server) handlePickupPoints(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		points, err := s.wrap.ListPickupPoints(r.Context())
+		if err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, points)
+	case http.MethodPost:
+		var req pickupPointRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperr.WriteProblem(w, r, errMalformedJSON)
+			return
+		}
+		p := req.pickupPoint()
+		if err := s.wrap.CreatePickupPoint(r.Context(), p); err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, p)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+// handlePickupPointOne serves GET and PUT /pickup-points/{id}.
+func (s *Server) handlePickupPointOne(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/pickup-points/")
+	switch r.Method {
+	case http.MethodGet:
+		p, err := s.wrap.GetPickupPoint(r.Context(), id)
+		if err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	case http.MethodPut:
+		s.handleUpdatePickupPoint(w, r, id)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+func (s *Server) handleUpdatePickupPoint(w http.ResponseWriter, r *http.Request, id string) {
+	var req pickupPointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.WriteProblem(w, r, errMalformedJSON)
+		return
+	}
+	if req.ID != "" && req.ID != id {
+		apperr.WriteProblem(w, r, apperr.NewValidation(apperr.Field("id", "must match the id in the URL")))
+		return
+	}
+	req.ID = id
+	p := req.pickupPoint()
+	if err := s.wrap.UpdatePickupPoint(r.Context(), p); err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"homework/internal/apperr"
+	"homework/internal/auth"
+	"homework/internal/models"
+	"homework/internal/orderio"
+)
+
+type recipientRequest struct {
+	ID     string                 `json:"id"`
+	Name   string                 `json:"name"`
+	Phone  string                 `json:"phone"`
+	Email  string                 `json:"email"`
+	Status models.RecipientStatus `json:"status"`
+}
+
+func (req recipientRequest) recipient() *models.Recipient {
+	return &models.Recipient{ID: req.ID, Name: req.Name, Phone: req.Phone, Email: req.Email, Status: req.Status}
+}
+
+// recipientOrdersResponse groups the orders of a recipient by state name.
+type recipientOrdersResponse struct {
+	Recipient *models.Recipient          `json:"recipient"`
+	Orders    map[string][]*models.Order `json:"orders"`
+}
+
+func (s *Server) registerRecipientRoutes(mux *http.ServeMux) {
+	s.handleWith(mux, "/recipients", s.handleRecipients, auth.MethodPermissions{
+		http.MethodGet:  auth.PermReadRecipients,
+		http.MethodPost: auth.PermManageRecipients,
+	})
+	s.handleWith(mux, "/recipients/", s.handleRecipientOne, auth.MethodPermissions{
+		http.MethodGet:    auth.PermReadRecipients,
+		http.MethodPut:    auth.PermManageRecipients,
+		http.MethodDelete: auth.PermManageRecipients,
+	})
+}
+
+// handleRecipients serves GET /recipients, ordered by id and paged with
+// after (the last id seen) and limit, and POST /recipients.
+func (s *Server) handleRecipients(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		limit, err := parseLimit(r.URL.Query().Get("limit"), defaultListLimit)
+		if err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		recipients, err := s.wrap.ListRecipients(r.Context(), r.URL.Query().Get("after"), limit)
+		if err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, recipients)
+	case http.MethodPost:
+		var req recipientRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperr.WriteProblem(w, r, errMalformedJSON)
+			return
+		}
+		rc := req.recipient()
+		if err := s.wrap.CreateRecipient(r.Context(), rc); err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, rc)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+// handleRecipientOne serves GET, PUT and DELETE /recipients/{id} and
+// GET /recipients/{id}/orders.
+func (s *Server) handleRecipientOne(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/recipients/")
+	if recipientID, ok := strings.CutSuffix(id, "/orders"); ok {
+		s.handleRecipientOrders(w, r, recipientID)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		rc, err := s.wrap.GetRecipient(r.Context(), id)
+		if err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rc)
+	case http.MethodPut:
+		s.handleUpdateRecipient(w, r, id)
+	case http.MethodDelete:
+		if err := s.wrap.DeleteRecipient(r.Context(), id); err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+func (s *Server) handleUpdateRecipient(w http.ResponseWriter, r *http.Request, id string) {
+	var req recipientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.WriteProblem(w, r, errMalformedJSON)
+		return
+	}
+	if req.ID != "" && req.ID != id {
+		apperr.WriteProblem(w, r, apperr.NewValidation(apperr.Field("id", "must match the id in the URL")))
+		return
+	}
+	req.ID = id
+	rc := req.recipient()
+	if err := s.wrap.UpdateRecipient(r.Context(), rc); err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rc)
+}
+
+// handleRecipientOrders lists the orders of a recipient grouped by state,
+// optionally narrowed to the states given in state.
+func (s *Server) handleRecipientOrders(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+	p := &filterParser{q: r.URL.Query()}
+	states := p.states("state")
+	if err := p.err(); err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	rc, orders, err := s.wrap.RecipientOrders(r.Context(), id, states)
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	resp := recipientOrdersResponse{Recipient: rc, Orders: make(map[string][]*models.Order)}
+	for _, o := range orders {
+		state := orderio.StateName(o.CurrentState())
+		resp.Orders[state] = append(resp.Orders[state], o)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+const defaultReturnsLimit = 10
+
+// handleGetReturns serves one page of client returns ordered by return time.
+// The body stays the bare array it has always been; the cursor of the next
+// page travels in the X-Next-Cursor and Link headers. offset still works but
+// is deprecated and answered with a Deprecation header.
+func (s *Server) handleGetReturns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+	q, err := s.parseReturnsQuery(r.URL)
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	if q.Offset > 0 {
+		w.Header().Set("Deprecation", "true")
+	}
+	orders, more, err := s.wrap.GetReturns(r.Context(), q)
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
+	}
+	if orders == nil {
+		orders = []*models.Order{}
+	}
+	if more {
+		last := orders[len(orders)-1]
+		next, err := s.encodeCursor(repository.ReturnKey(last), last.ID, r.URL)
+		if err != nil {
+			apperr.WriteProblem(w, r, err)
+			return
+		}
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextPageURL(r.URL, next)))
+	}
+	writeJSON(w, http.StatusOK, orders)
+}
+
+// nextPageURL is u positioned at cursor instead of its current page.
+func nextPageURL(u *url.URL, cursor string) string {
+	q := u.Query()
+	q.Del("offset")
+	q.Set("cursor", cursor)
+	return (&url.URL{Path: u.Path, RawQuery: q.Encode()}).String()
+}
+
+func (s *Server) parseReturnsQuery(u *url.URL) (repository.ReturnsQuery, error) {
+	values := u.Query()
+	q := repository.ReturnsQuery{RecipientID: values.Get("recipient_id"), PickupPointID: values.Get("pickup_point_id")}
+	limit, err := parseLimit(values.Get("limit"), defaultReturnsLimit)
+	if err != nil {
+		return q, err
+	}
+	q.Limit = int64(limit)
+	token, offset := values.Get("cursor"), values.Get("offset")
+	switch {
+	case token != "" && offset != "":
+		return q, apperr.NewValidation(apperr.Field("offset", "cannot be combined with cursor"))
+	case token != "":
+		q.After, err = s.decodeCursor(token, u)
+	case offset != "":
+		q.Offset, err = strconv.ParseInt(offset, 10, 64)
+		if err != nil || q.Offset < 0 {
+			err = apperr.NewValidation(apperr.Field("offset", "must be a non-negative integer"))
+		}
+	}
+	return q, err
+}
@@ -3,41 +3,66 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"homework/internal/audit"
+	"homework/internal/auth"
 	"homework/internal/config"
+	"homework/internal/idempotency"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
+	"homework/internal/apperr"
+	"homework/internal/cursor"
+	"homework/internal/metrics"
 	"homework/internal/middleware"
 	"homework/internal/models"
+	"homework/internal/ratelimit"
+	"homework/internal/repository"
 	"homework/internal/wrapper"
 )
 
 type Server struct {
-	wrap      *wrapper.OrderWrapper
-	user      string
-	password  string
-	addr      string
-	auditPool *audit.AuditWorkerPool
+	wrap       *wrapper.OrderWrapper
+	authn      *auth.Authenticator
+	rateLimits config.RateLimitConfig
+	// authFailures counts failed authentication per client IP, shared by
+	// every route; nil when the limit is disabled.
+	authFailures *ratelimit.Limiter
+	idem         idempotency.Store
+	idemTTL      time.Duration
+	cursors      *cursor.Codec
+	addr         string
+	auditPool    *audit.AuditWorkerPool
+	http         *http.Server
 }
 
-func NewServer(wrap *wrapper.OrderWrapper, cfg *config.Config, auditPool *audit.AuditWorkerPool) *Server {
-	return &Server{
-		wrap:      wrap,
-		user:      cfg.Username,
-		password:  cfg.Password,
-		addr:      cfg.Addr(),
-		auditPool: auditPool,
+func NewServer(wrap *wrapper.OrderWrapper, cfg *config.Config, auditPool *audit.AuditWorkerPool, authn *auth.Authenticator, idem idempotency.Store, cursors *cursor.Codec) *Server {
+	s := &Server{
+		wrap:       wrap,
+		authn:      authn,
+		rateLimits: cfg.RateLimits,
+		idem:       idem,
+		idemTTL:    cfg.IdempotencyTTL,
+		cursors:    cursors,
+		addr:       cfg.Addr(),
+		auditPool:  auditPool,
 	}
+	if l := cfg.RateLimits; l.AuthFailureRate > 0 {
+		s.authFailures = ratelimit.NewLimiter(l.AuthFailureRate, l.AuthFailureBurst)
+	}
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	s.http = &http.Server{Addr: s.addr, Handler: mux}
+	return s
 }
 
-func (s *Server) logStatusTransition(orderID, oldState, newState, endpoint string) {
+func (s *Server) logStatusTransition(ctx context.Context, orderID, oldState, newState, endpoint string) {
 	s.auditPool.Log(audit.AuditLog{
 		Timestamp: time.Now().UTC(),
+		Principal: auth.ActorFromContext(ctx),
 		OrderID:   orderID,
 		OldState:  oldState,
 		NewState:  newState,
@@ -49,63 +74,111 @@ func (s *Server) logStatusTransition(orderID, oldState, newState, endpoint strin
 }
 
 func (s *Server) RegisterRoutes(mux *http.ServeMux) {
+	s.handleWith(mux, "/orders", s.handleOrders, auth.MethodPermissions{
+		http.MethodGet:  auth.PermRead,
+		http.MethodPost: auth.PermCreate,
+	})
 
-	s.handleWith(mux, "/orders", s.handleOrders,
-		[]string{"POST"},
-	)
-
-	s.handleWith(mux, "/orders/", s.handleOrderOne,
-		[]string{"POST", "PUT", "DELETE"},
-	)
-
-	s.handleWith(mux, "/orders-deliver/", s.handleDeliver,
-		[]string{"PUT"},
-	)
-
-	s.handleWith(mux, "/orders-return/", s.handleClientReturn,
-		[]string{"PUT"},
-	)
+	s.handleWith(mux, "/orders:batch", s.handleBatchOrders, auth.MethodPermissions{
+		http.MethodPost: auth.PermCreate,
+	})
 
-	s.handleWith(mux, "/orders-accept/", s.handleAccept, []string{"PUT"})
-	s.handleWith(mux, "/orders-courier-return/", s.handleCourierReturn, []string{"PUT"})
+	s.handleWith(mux, "/orders:export", s.handleExportOrders, auth.MethodPermissions{
+		http.MethodGet: auth.PermRead,
+	})
 
-	mux.Handle("/returns", middleware.AuditResponseMiddleware(s.auditPool)(http.HandlerFunc(s.handleGetReturns)))
+	s.handleWith(mux, "/orders/", s.handleOrderOne, auth.MethodPermissions{
+		http.MethodGet:    auth.PermRead,
+		http.MethodPut:    auth.PermUpdate,
+		http.MethodPatch:  auth.PermUpdate,
+		http.MethodDelete: auth.PermDelete,
+	})
 
-	mux.Handle("/history", middleware.AuditResponseMiddleware(s.auditPool)(http.HandlerFunc(s.handleOrderHistory)))
-}
+	s.handleWith(mux, "/orders-deliver/", s.handleDeliver, auth.MethodPermissions{
+		http.MethodPut: auth.PermDeliver,
+	})
 
-func (s *Server) Run() error {
-	ctx, cancel := context.WithCancel(context.Background())
+	s.handleWith(mux, "/orders-return/", s.handleClientReturn, auth.MethodPermissions{
+		http.MethodPut: auth.PermClientReturn,
+	})
 
-	s.auditPool.Start(ctx)
+	s.handleWith(mux, "/orders-accept/", s.handleAccept, auth.MethodPermissions{
+		http.MethodPut: auth.PermAccept,
+	})
+	s.handleWith(mux, "/orders-courier-return/", s.handleCourierReturn, auth.MethodPermissions{
+		http.MethodPut: auth.PermCourierReturn,
+	})
 
-	defer func() {
-		cancel()
-		s.auditPool.Shutdown()
-	}()
+	s.handleWith(mux, "/returns", s.handleGetReturns, auth.MethodPermissions{
+		http.MethodGet: auth.PermRead,
+	})
+	s.handleWith(mux, "/history", s.handleOrderHistory, auth.MethodPermissions{
+		http.MethodGet: auth.PermRead,
+	})
 
-	mux := http.NewServeMux()
+	s.registerAuthRoutes(mux)
+	s.registerAPIKeyRoutes(mux)
+	s.registerRecipientRoutes(mux)
+	s.registerPickupPointRoutes(mux)
 
-	s.RegisterRoutes(mux)
+	mux.Handle("/metrics", metrics.Handler())
+}
 
+// Run serves HTTP until Shutdown is called.
+func (s *Server) Run() error {
 	log.Printf("Server listen on %s...", s.addr)
-	return http.ListenAndServe(s.addr, mux)
+	if err := s.http.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish or for ctx to be done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.http.Shutdown(ctx)
 }
 
 func (s *Server) handleWith(mux *http.ServeMux, path string,
 	handlerFunc http.HandlerFunc,
-	methods []string,
+	perms auth.MethodPermissions,
 ) {
-	finalHandler := middleware.AuditResponseMiddleware(s.auditPool)(
-		middleware.LogMiddleware(s.auditPool, methods...)(
-			middleware.BasicAuthMiddleware(s.user, s.password, methods...)(
-				handlerFunc,
+	finalHandler := middleware.MetricsMiddleware(path)(
+		middleware.AuthFailureLimit(path, s.authFailures)(
+			s.authenticate(
+				middleware.RateLimitMiddleware(path, s.rateLimits.For(path))(
+					middleware.AuditResponseMiddleware(s.auditPool)(
+						middleware.LogMiddleware(s.auditPool)(
+							middleware.Authorize(perms)(
+								middleware.IdempotencyMiddleware(s.idem, s.idemTTL, idempotentBodyLimit(path))(
+									handlerFunc,
+								),
+							),
+						),
+					),
+				),
 			),
 		),
 	)
 	mux.Handle(path, finalHandler)
 }
 
+// idempotentBodyLimit is how much of a request body to path the idempotency
+// middleware may buffer: batch uploads are as large as their handler allows.
+func idempotentBodyLimit(path string) int64 {
+	if path == "/orders:batch" {
+		return maxBatchBytes
+	}
+	return middleware.DefaultMaxIdempotentBody
+}
+
+// authenticate accepts basic auth, bearer tokens and API keys alike.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	next = middleware.APIKeyAuthenticate(s.authn.APIKeys)(next)
+	next = middleware.BearerAuthenticate(s.authn.Tokens)(next)
+	return middleware.Authenticate(s.authn.Users)(next)
+}
+
 func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -113,14 +186,18 @@ func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		s.handleListOrders(w, r)
 	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r)
 	}
 }
 
 func (s *Server) handleOrderOne(w http.ResponseWriter, r *http.Request) {
 	id := strings.TrimPrefix(r.URL.Path, "/orders/")
 	if id == "" {
-		http.Error(w, "missing ID", http.StatusBadRequest)
+		apperr.WriteProblem(w, r, apperr.NewValidation(apperr.Field("id", "is required")))
+		return
+	}
+	if orderID, ok := strings.CutSuffix(id, "/history"); ok {
+		s.handleOrderEvents(w, r, orderID)
 		return
 	}
 	switch r.Method {
@@ -128,68 +205,77 @@ func (s *Server) handleOrderOne(w http.ResponseWriter, r *http.Request) {
 		s.handleGetOrder(w, r, id)
 	case http.MethodPut:
 		s.handleUpdateOrder(w, r, id)
+	case http.MethodPatch:
+		s.handlePatchOrder(w, r, id)
 	case http.MethodDelete:
 		s.handleDeleteOrder(w, r, id)
 	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r)
+	}
+}
+
+func (s *Server) handleOrderEvents(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+	events, err := s.wrap.OrderHistory(r.Context(), id)
+	if err != nil {
+		apperr.WriteProblem(w, r, err)
+		return
 	}
+	writeJSON(w, http.StatusOK, events)
 }
 
 func (s *Server) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
 	var o models.Order
 	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
-		http.Error(w, "bad JSON", http.StatusBadRequest)
-		return
-	}
-	if time.Now().After(o.StorageDeadline) {
+		apperr.WriteProblem(w, r, errMalformedJSON)
 		return
 	}
 	o.LastStateChange = time.Now().UTC()
 
-	if err := s.wrap.CreateOrder(&o); err != nil {
-		http.Error(w, err.Error(), http.StatusConflict)
+	if err := s.wrap.CreateOrder(r.Context(), &o); err != nil {
+		apperr.WriteProblem(w, r, err)
 		return
 	}
 
 	writeJSON(w, http.StatusCreated, o)
-	s.logStatusTransition(o.ID, "", string(models.OrderStateAccepted), r.URL.Path)
+	s.logStatusTransition(r.Context(), o.ID, "", string(models.OrderStateAccepted), r.URL.Path)
 }
 
-func (s *Server) handleListOrders(w http.ResponseWriter, _ *http.Request) {
-	orders, err := s.wrap.ListActiveOrders()
+func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request, id string) {
+	o, err := s.wrap.GetOrderByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	writeJSON(w, http.StatusOK, orders)
-}
-
-func (s *Server) handleGetOrder(w http.ResponseWriter, _ *http.Request, id string) {
-	o, err := s.wrap.GetOrderByID(id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apperr.WriteProblem(w, r, err)
 		return
 	}
 	if o == nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		apperr.WriteProblem(w, r, fmt.Errorf("order %s: %w", id, repository.ErrOrderNotFound))
 		return
 	}
+	w.Header().Set("ETag", etag(o.Version))
 	writeJSON(w, http.StatusOK, o)
 }
 
 func (s *Server) handleUpdateOrder(w http.ResponseWriter, r *http.Request, id string) {
+	version, err := s.ifMatchVersion(r, id)
+	if err != nil {
+		writeIfMatchError(w, r, err)
+		return
+	}
 	var updated models.Order
 	if err := json.NewDecoder(r.Body).Decode(&updated); err != nil {
-		http.Error(w, "bad JSON", http.StatusBadRequest)
+		apperr.WriteProblem(w, r, errMalformedJSON)
 		return
 	}
 	if updated.ID != id {
-		http.Error(w, "ID mismatch", http.StatusBadRequest)
+		apperr.WriteProblem(w, r, apperr.NewValidation(apperr.Field("id", "must match the ID in the URL")))
 		return
 	}
-	oldOrder, err := s.wrap.GetOrderByID(id)
+	oldOrder, err := s.wrap.GetOrderByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		apperr.WriteProblem(w, r, err)
 		return
 	}
 	oldState := ""
@@ -198,113 +284,109 @@ func (s *Server) handleUpdateOrder(w http.ResponseWriter, r *http.Request, id st
 	}
 
 	updated.LastStateChange = time.Now().UTC()
-	if err := s.wrap.UpdateOrder(&updated); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.updateIfMatch(r.Context(), &updated, version); err != nil {
+		apperr.WriteProblem(w, r, err)
 		return
 	}
+	w.Header().Set("ETag", etag(updated.Version))
 	writeJSON(w, http.StatusOK, updated)
 	newState := string(updated.CurrentState())
 	if oldState != newState {
-		s.logStatusTransition(id, oldState, newState, r.URL.Path)
+		s.logStatusTransition(r.Context(), id, oldState, newState, r.URL.Path)
 	}
 }
 
-func (s *Server) handleDeleteOrder(w http.ResponseWriter, _ *http.Request, id string) {
-	if err := s.wrap.DeleteOrder(id); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+func (s *Server) handleDeleteOrder(w http.ResponseWriter, r *http.Request, id string) {
+	if err := s.wrap.DeleteOrder(r.Context(), id); err != nil {
+		apperr.WriteProblem(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s.logStatusTransition(id, "existing", "deleted", "/orders/"+id)
+	s.logStatusTransition(r.Context(), id, "existing", "deleted", "/orders/"+id)
 }
 
 func (s *Server) handleDeliver(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r)
 		return
 	}
 	id := strings.TrimPrefix(r.URL.Path, "/orders-deliver/")
-	if err := s.wrap.DeliverOrder(id); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if err := s.wrap.DeliverOrder(r.Context(), id); err != nil {
+		apperr.WriteProblem(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	s.logStatusTransition(id, "", string(models.OrderStateDelivered), r.URL.Path)
+	s.logStatusTransition(r.Context(), id, "", string(models.OrderStateDelivered), r.URL.Path)
 }
 
 func (s *Server) handleClientReturn(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r)
 		return
 	}
 	id := strings.TrimPrefix(r.URL.Path, "/orders-return/")
-	if err := s.wrap.ClientReturnOrder(id); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if err := s.wrap.ClientReturnOrder(r.Context(), id); err != nil {
+		apperr.WriteProblem(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	s.logStatusTransition(id, "", string(models.OrderStateClientRtn), r.URL.Path)
-}
-
-func (s *Server) handleGetReturns(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	q := r.URL.Query()
-	offset, _ := strconv.ParseInt(q.Get("offset"), 10, 64)
-	limit, _ := strconv.ParseInt(q.Get("limit"), 10, 64)
-	recipientID := q.Get("recipient_id")
-
-	orders, err := s.wrap.GetReturns(offset, limit, recipientID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	writeJSON(w, http.StatusOK, orders)
+	s.logStatusTransition(r.Context(), id, "", string(models.OrderStateClientRtn), r.URL.Path)
 }
 
 func (s *Server) handleAccept(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r)
 		return
 	}
 	id := strings.TrimPrefix(r.URL.Path, "/orders-accept/")
-	if err := s.wrap.AcceptOrder(id); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if err := s.wrap.AcceptOrder(r.Context(), id); err != nil {
+		apperr.WriteProblem(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	s.logStatusTransition(id, "", string(models.OrderStateAccepted), r.URL.Path)
+	s.logStatusTransition(r.Context(), id, "", string(models.OrderStateAccepted), r.URL.Path)
 
 }
 
 func (s *Server) handleCourierReturn(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r)
 		return
 	}
 	id := strings.TrimPrefix(r.URL.Path, "/orders-courier-return/")
-	if err := s.wrap.CourierReturnOrder(id); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if err := s.wrap.CourierReturnOrder(r.Context(), id); err != nil {
+		apperr.WriteProblem(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	s.logStatusTransition(id, "", string(models.OrderStateReturned), r.URL.Path)
+	s.logStatusTransition(r.Context(), id, "", string(models.OrderStateReturned), r.URL.Path)
 }
 
-func (s *Server) handleOrderHistory(w http.ResponseWriter, _ *http.Request) {
-	orders, err := s.wrap.ListHistoryOrders()
+func (s *Server) handleOrderHistory(w http.ResponseWriter, r *http.Request) {
+	orders, err := s.wrap.ListHistoryOrders(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apperr.WriteProblem(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, orders)
 }
 
+var errMalformedJSON = apperr.New(apperr.Validation, "malformed_json", "malformed JSON body")
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	apperr.WriteStatus(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+}
+
 func writeJSON(w http.ResponseWriter, code int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(code)
 	_ = json.NewEncoder(w).Encode(data)
 }
+
+// writeSecret is writeJSON for responses carrying a credential. No-store
+// keeps them out of caches and out of the idempotency store.
+func writeSecret(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, code, data)
+}
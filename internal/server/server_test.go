@@ -0,0 +1,155 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"homework/internal/apperr"
+	"homework/internal/audit"
+	"homework/internal/auth"
+	"homework/internal/cache"
+	"homework/internal/config"
+	"homework/internal/cursor"
+	"homework/internal/models"
+	"homework/internal/packaging"
+	"homework/internal/repository/memory"
+	"homework/internal/server"
+	"homework/internal/service"
+	"homework/internal/wrapper"
+)
+
+// testServer serves the HTTP routes over in-memory repositories to an admin
+// authenticated with basic auth.
+type testServer struct {
+	handler http.Handler
+	repo    *memory.OrderRepository
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users, err := auth.NewUserStore(auth.User{Name: "admin", Role: auth.RoleAdmin, PasswordHash: string(hash)})
+	require.NoError(t, err)
+	tokens, err := auth.NewTokenIssuer([]byte(strings.Repeat("k", 32)), "orders", time.Hour, memory.NewRevokedTokenRepository())
+	require.NoError(t, err)
+
+	repo := memory.NewOrderRepository()
+	recipients := service.NewRecipientService(memory.NewRecipientRepository(), config.OperationTimeouts{}, true)
+	points := service.NewPickupPointService(repo, config.OperationTimeouts{}, "")
+	orders := service.NewOrderService(repo, cache.NewActiveOrdersCache(), cache.NewHistoryCache(),
+		config.OperationTimeouts{}, service.NewValidator(0, packaging.Default), recipients, points)
+	srv := server.NewServer(wrapper.NewOrderWrapper(orders, recipients, points), &config.Config{},
+		audit.NewAuditWorkerPool(),
+		&auth.Authenticator{Users: users, Tokens: tokens, APIKeys: auth.NewAPIKeys(memory.NewAPIKeyRepository())},
+		memory.NewIdempotencyRepository(), cursor.NewCodec([]byte("test cursor key")))
+	mux := http.NewServeMux()
+	srv.RegisterRoutes(mux)
+	return &testServer{handler: mux, repo: repo}
+}
+
+// do sends a request as the admin; header holds name, value pairs.
+func (ts *testServer) do(method, target, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.SetBasicAuth("admin", "secret")
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	return w
+}
+
+// createOrder stores an order of the given weight for recipient user1
+// through the API.
+func (ts *testServer) createOrder(t *testing.T, id string, weight float64) {
+	t.Helper()
+	body, err := json.Marshal(&models.Order{
+		ID: id, RecipientID: "user1", StorageDeadline: time.Now().Add(24 * time.Hour), Weight: weight, Cost: 100,
+	})
+	require.NoError(t, err)
+	w := ts.do(http.MethodPost, "/orders", string(body))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+}
+
+// assertProblem checks that w holds a problem document with status and code.
+func assertProblem(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	assert.Equal(t, status, w.Code)
+	assert.Equal(t, apperr.ProblemContentType, w.Header().Get("Content-Type"))
+	var p apperr.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, code, p.Code)
+}
+
+func TestOrderETag(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createOrder(t, "ord1", 2)
+
+	w := ts.do(http.MethodGet, "/orders/ord1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+}
+
+func TestUpdateOrderIfMatch(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createOrder(t, "ord1", 2)
+	put := func(ifMatch string) *httptest.ResponseRecorder {
+		body := `{"id":"ord1","recipient_id":"user1","storage_deadline":"` +
+			time.Now().Add(48*time.Hour).UTC().Format(time.RFC3339) + `","weight":3,"cost":100}`
+		if ifMatch == "" {
+			return ts.do(http.MethodPut, "/orders/ord1", body)
+		}
+		return ts.do(http.MethodPut, "/orders/ord1", body, "If-Match", ifMatch)
+	}
+
+	assertProblem(t, put(""), http.StatusPreconditionRequired, "if_match_required")
+	assertProblem(t, put(`3`), http.StatusBadRequest, "invalid_if_match")
+
+	w := put(`"1"`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	assertProblem(t, put(`"1"`), http.StatusPreconditionFailed, "version_mismatch")
+	assertProblem(t, put(`W/"2"`), http.StatusPreconditionFailed, "version_mismatch")
+	assertProblem(t, put(`"7", "8"`), http.StatusPreconditionFailed, "version_mismatch")
+
+	w = put(`"1", W/"2", "2"`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+	w = put(`*`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+}
+
+func TestPatchOrderIfMatch(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createOrder(t, "ord1", 2)
+	patch := func(ifMatch string) *httptest.ResponseRecorder {
+		return ts.do(http.MethodPatch, "/orders/ord1", `{"weight":4}`,
+			"Content-Type", "application/merge-patch+json", "If-Match", ifMatch)
+	}
+
+	assertProblem(t, patch(`"2"`), http.StatusPreconditionFailed, "version_mismatch")
+	assertProblem(t, patch(`W/"1"`), http.StatusPreconditionFailed, "version_mismatch")
+
+	// A write past the service leaves the cached order behind; "*" must
+	// still patch the stored one.
+	stored, err := ts.repo.GetID(context.Background(), "ord1")
+	require.NoError(t, err)
+	require.NoError(t, ts.repo.UpdateTx(context.Background(), stored))
+
+	w := patch(`*`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+	"homework/internal/orderio"
+	"homework/internal/repository"
+)
+
+// BatchMode selects what happens to the valid rows of an import when some
+// rows fail.
+type BatchMode string
+
+const (
+	// BatchAtomic creates every row or none.
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort creates every valid row and reports the rest.
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+type BatchRowStatus string
+
+const (
+	BatchRowCreated BatchRowStatus = "created"
+	BatchRowFailed  BatchRowStatus = "failed"
+	// BatchRowSkipped marks a valid row that was not created because an
+	// atomic batch failed elsewhere.
+	BatchRowSkipped BatchRowStatus = "skipped"
+)
+
+// BatchRowResult is the outcome of one imported row. Code, Detail and Errors
+// follow the problem documents of the HTTP API.
+type BatchRowResult struct {
+	Row    int                 `json:"row"`
+	ID     string              `json:"id,omitempty"`
+	Status BatchRowStatus      `json:"status"`
+	Code   string              `json:"code,omitempty"`
+	Detail string              `json:"detail,omitempty"`
+	Errors []apperr.FieldError `json:"errors,omitempty"`
+}
+
+type BatchReport struct {
+	Mode    BatchMode        `json:"mode"`
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Skipped int              `json:"skipped"`
+	Rows    []BatchRowResult `json:"rows"`
+}
+
+// ImportOrders validates every row and creates the valid ones in a single
+// repository batch. Row problems end up in the report; the returned error is
+// reserved for failures of the import as a whole.
+func (s *OrderService) ImportOrders(ctx context.Context, rows []orderio.Row, mode BatchMode) (*BatchReport, error) {
+	report := &BatchReport{Mode: mode, Rows: make([]BatchRowResult, len(rows))}
+	valid := s.validateBatch(rows, report)
+	valid, err := s.admitBatch(ctx, rows, valid, report)
+	if err != nil {
+		return nil, err
+	}
+	if mode == BatchAtomic && len(valid) < len(rows) {
+		report.skip(valid)
+		return report, nil
+	}
+	if len(valid) == 0 {
+		return report, nil
+	}
+
+	orders := make([]*models.Order, 0, len(valid))
+	for _, i := range valid {
+		orders = append(orders, rows[i].Order)
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	existing, err := s.repo.CreateBatch(ctx, orders, mode == BatchAtomic)
+	if err != nil && !errors.Is(err, repository.ErrOrderExists) {
+		return nil, err
+	}
+	s.applyBatchResult(rows, valid, existing, report)
+	return report, nil
+}
+
+// validateBatch fills the report for rows that fail decoding or validation
+// and returns the indexes of the remaining ones.
+func (s *OrderService) validateBatch(rows []orderio.Row, report *BatchReport) []int {
+	now := time.Now().UTC()
+	firstLine := make(map[string]int, len(rows))
+	var valid []int
+	for i, row := range rows {
+		report.Rows[i] = BatchRowResult{Row: row.Line}
+		err := row.Err
+		if err == nil {
+			report.Rows[i].ID = row.Order.ID
+			err = s.validator.ValidateNew(row.Order)
+		}
+		if err == nil {
+			if line, dup := firstLine[row.Order.ID]; dup {
+				err = apperr.NewValidation(apperr.Field("id", "duplicates row %d", line))
+			} else {
+				firstLine[row.Order.ID] = row.Line
+			}
+		}
+		if err != nil {
+			report.fail(i, err)
+			continue
+		}
+		row.Order.LastStateChange = now
+		valid = append(valid, i)
+	}
+	return valid
+}
+
+// admitBatch checks the recipient and the pickup point of every valid row,
+// once per recipient and point, fails the rows that may not be created and
+// returns the rest.
+func (s *OrderService) admitBatch(ctx context.Context, rows []orderio.Row, valid []int, report *BatchReport) ([]int, error) {
+	recipients := make(map[string]error)
+	points := make(map[string]error)
+	admitted := valid[:0]
+	for _, i := range valid {
+		o := rows[i].Order
+		verdict, err := checkOnce(recipients, o.RecipientID, func() error {
+			return s.recipients.admit(ctx, o.RecipientID)
+		})
+		if err == nil && verdict == nil {
+			o.PickupPointID = s.points.assign(ctx, o.PickupPointID, "")
+			verdict, err = checkOnce(points, o.PickupPointID, func() error {
+				return s.points.check(ctx, o.PickupPointID)
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+		if verdict != nil {
+			report.fail(i, verdict)
+			continue
+		}
+		admitted = append(admitted, i)
+	}
+	return admitted, nil
+}
+
+// checkOnce runs check for key unless verdicts already holds the answer.
+// Failures that are not about the row itself, such as a lost database, are
+// returned as err and abort the import.
+func checkOnce(verdicts map[string]error, key string, check func() error) (verdict, err error) {
+	if verdict, ok := verdicts[key]; ok {
+		return verdict, nil
+	}
+	verdict = check()
+	if kind := apperr.KindOf(verdict); verdict != nil && kind != apperr.Validation && kind != apperr.Conflict {
+		return nil, verdict
+	}
+	verdicts[key] = verdict
+	return verdict, nil
+}
+
+// applyBatchResult marks the rows the repository reported as existing and
+// caches the created orders. In atomic mode a conflict means nothing was
+// written.
+func (s *OrderService) applyBatchResult(rows []orderio.Row, valid []int, existing []string, report *BatchReport) {
+	taken := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		taken[id] = true
+	}
+	var created []*models.Order
+	for _, i := range valid {
+		o := rows[i].Order
+		if taken[o.ID] {
+			report.fail(i, fmt.Errorf("order %s: %w", o.ID, repository.ErrOrderExists))
+			continue
+		}
+		created = append(created, o)
+	}
+	if report.Mode == BatchAtomic && len(existing) > 0 {
+		report.skip(valid)
+		return
+	}
+	s.activeCache.Mu.Lock()
+	for _, o := range created {
+		s.activeCache.Orders[o.ID] = o
+	}
+	s.activeCache.Mu.Unlock()
+	report.Created = len(created)
+	for _, i := range valid {
+		if report.Rows[i].Status == "" {
+			report.Rows[i].Status = BatchRowCreated
+		}
+	}
+}
+
+func (r *BatchReport) fail(i int, err error) {
+	e := apperr.From(err)
+	r.Rows[i].Status = BatchRowFailed
+	r.Rows[i].Code = e.Code
+	r.Rows[i].Detail = apperr.Detail(err)
+	r.Rows[i].Errors = e.Fields
+	r.Failed++
+}
+
+// skip marks the rows of valid that have not failed as skipped.
+func (r *BatchReport) skip(valid []int) {
+	for _, i := range valid {
+		if r.Rows[i].Status == "" {
+			r.Rows[i].Status = BatchRowSkipped
+			r.Skipped++
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/models"
+	"homework/internal/orderio"
+	"homework/internal/service"
+)
+
+func batchRows(ids ...string) []orderio.Row {
+	rows := make([]orderio.Row, 0, len(ids))
+	for i, id := range ids {
+		rows = append(rows, orderio.Row{Line: i + 1, Order: &models.Order{
+			ID:              id,
+			RecipientID:     "user1",
+			StorageDeadline: time.Now().Add(24 * time.Hour),
+			Weight:          2,
+			Cost:            100,
+		}})
+	}
+	return rows
+}
+
+func TestImportOrders(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestOrderService(t, testOptions{})
+	repo, svc := ts.repo, ts.orders
+	require.NoError(t, repo.Create(ctx, batchRows("ord0")[0].Order))
+
+	rows := batchRows("ord1", "ord0", "ord2", "ord2", "bad id")
+	report, err := svc.ImportOrders(ctx, rows, service.BatchAtomic)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, service.BatchRowSkipped, report.Rows[0].Status)
+	assert.Equal(t, "validation_failed", report.Rows[3].Code)
+
+	report, err = svc.ImportOrders(ctx, batchRows("ord1", "ord0", "ord2", "ord2", "bad id"), service.BatchBestEffort)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 3, report.Failed)
+	assert.Equal(t, service.BatchRowCreated, report.Rows[0].Status)
+	assert.Equal(t, "order_exists", report.Rows[1].Code)
+	assert.Equal(t, service.BatchRowCreated, report.Rows[2].Status)
+
+	report, err = svc.ImportOrders(ctx, batchRows("ord3", "ord0"), service.BatchAtomic)
+	require.NoError(t, err)
+	assert.Equal(t, service.BatchRowSkipped, report.Rows[0].Status)
+	assert.Equal(t, "order_exists", report.Rows[1].Code)
+
+	report, err = svc.ImportOrders(ctx, batchRows("ord3", "ord4"), service.BatchAtomic)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	got, err := repo.GetID(ctx, "ord4")
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+}
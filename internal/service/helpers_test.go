@@ -0,0 +1,41 @@
+package service_test
+
+import (
+	"testing"
+
+	"homework/internal/cache"
+	"homework/internal/config"
+	"homework/internal/packaging"
+	"homework/internal/repository/memory"
+	"homework/internal/service"
+)
+
+// testOptions vary the services newTestOrderService builds. The zero value
+// auto-creates recipients and leaves orders without a pickup point.
+type testOptions struct {
+	rejectUnknownRecipients bool
+	defaultPickupPoint      string
+}
+
+// testServices is an order service over in-memory repositories, with the
+// services and the order repository behind it.
+type testServices struct {
+	orders     *service.OrderService
+	recipients *service.RecipientService
+	points     *service.PickupPointService
+	repo       *memory.OrderRepository
+}
+
+func newTestOrderService(t *testing.T, opts testOptions) *testServices {
+	t.Helper()
+	recipientRepo := memory.NewRecipientRepository()
+	repo := memory.NewOrderRepository().WithRecipients(recipientRepo)
+	ts := &testServices{
+		recipients: service.NewRecipientService(recipientRepo, config.OperationTimeouts{}, !opts.rejectUnknownRecipients),
+		points:     service.NewPickupPointService(repo, config.OperationTimeouts{}, opts.defaultPickupPoint),
+		repo:       repo,
+	}
+	ts.orders = service.NewOrderService(repo, cache.NewActiveOrdersCache(), cache.NewHistoryCache(),
+		config.OperationTimeouts{}, service.NewValidator(0, packaging.Default), ts.recipients, ts.points)
+	return ts
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"homework/internal/apperr"
+	"homework/internal/auth"
+	"homework/internal/config"
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+const maxPickupPointAddress = 500
+
+// PickupPointService manages pickup points and decides at which point an
+// order is kept.
+type PickupPointService struct {
+	repo      repository.PickupPointRepository
+	timeouts  config.OperationTimeouts
+	defaultID string
+}
+
+// NewPickupPointService returns a service that sends orders created without
+// a pickup point to defaultID. An empty defaultID leaves them unassigned.
+func NewPickupPointService(repo repository.PickupPointRepository, timeouts config.OperationTimeouts, defaultID string) *PickupPointService {
+	return &PickupPointService{repo: repo, timeouts: timeouts, defaultID: defaultID}
+}
+
+func (s *PickupPointService) CreatePickupPoint(ctx context.Context, p *models.PickupPoint) error {
+	if err := validatePickupPoint(p); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	return s.repo.CreatePickupPoint(ctx, p)
+}
+
+func (s *PickupPointService) GetPickupPoint(ctx context.Context, id string) (*models.PickupPoint, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	return s.repo.GetPickupPoint(ctx, id)
+}
+
+func (s *PickupPointService) ListPickupPoints(ctx context.Context) ([]*models.PickupPoint, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	return s.repo.ListPickupPoints(ctx)
+}
+
+func (s *PickupPointService) UpdatePickupPoint(ctx context.Context, p *models.PickupPoint) error {
+	if err := validatePickupPoint(p); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	return s.repo.UpdatePickupPoint(ctx, p)
+}
+
+// place settles the pickup point of an order that is currently at current,
+// "" for a new order, and checks that the caller may keep it there.
+func (s *PickupPointService) place(ctx context.Context, o *models.Order, current string) error {
+	o.PickupPointID = s.assign(ctx, o.PickupPointID, current)
+	return s.check(ctx, o.PickupPointID)
+}
+
+// assign returns requested or, if it is empty, the point the order is at,
+// the caller's own point or the default point, in that order.
+func (s *PickupPointService) assign(ctx context.Context, requested, current string) string {
+	return cmp.Or(requested, current, auth.PickupPointScope(ctx), s.defaultID)
+}
+
+// check fails with a validation error unless the point exists and the
+// caller in ctx is not bound to another one.
+func (s *PickupPointService) check(ctx context.Context, id string) error {
+	if id == "" {
+		return nil
+	}
+	if err := checkScope(ctx, id); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	_, err := s.repo.GetPickupPoint(ctx, id)
+	if errors.Is(err, repository.ErrPickupPointNotFound) {
+		return apperr.NewValidation(apperr.Field("pickup_point_id", "unknown pickup point %q", id))
+	}
+	return err
+}
+
+// checkScope fails with a validation error if the caller in ctx is bound to
+// a pickup point other than id.
+func checkScope(ctx context.Context, id string) error {
+	if scope := auth.PickupPointScope(ctx); scope != "" && id != scope {
+		return apperr.NewValidation(apperr.Field("pickup_point_id", "must be your pickup point %q", scope))
+	}
+	return nil
+}
+
+// scopeTo narrows a pickup point filter to the caller's own point. Asking for
+// another point is a validation error rather than an empty result.
+func scopeTo(ctx context.Context, point *string) error {
+	if *point != "" {
+		return checkScope(ctx, *point)
+	}
+	*point = auth.PickupPointScope(ctx)
+	return nil
+}
+
+// visible reports whether the caller in ctx may see o.
+func visible(ctx context.Context, o *models.Order) bool {
+	scope := auth.PickupPointScope(ctx)
+	return scope == "" || o.PickupPointID == scope
+}
+
+// validatePickupPoint reports every invalid field of p.
+func validatePickupPoint(p *models.PickupPoint) error {
+	var fields []apperr.FieldError
+	if !idPattern.MatchString(p.ID) {
+		fields = append(fields, apperr.Field("id", "must be 1-64 letters, digits, '-' or '_' and start with a letter or digit"))
+	}
+	if strings.TrimSpace(p.Address) == "" {
+		fields = append(fields, apperr.Field("address", "is required"))
+	} else if utf8.RuneCountInString(p.Address) > maxPickupPointAddress {
+		fields = append(fields, apperr.Field("address", "must be at most %d characters", maxPickupPointAddress))
+	}
+	if p.Capacity < 0 {
+		fields = append(fields, apperr.Field("capacity", "must not be negative"))
+	}
+	if len(fields) > 0 {
+		return apperr.NewValidation(fields...)
+	}
+	return nil
+}
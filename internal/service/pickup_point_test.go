@@ -0,0 +1,84 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/apperr"
+	"homework/internal/auth"
+	"homework/internal/models"
+	"homework/internal/repository"
+	"homework/internal/service"
+)
+
+// newPointServices returns an order service with two pickup points: pvz1,
+// the default, with a single shelf place and unlimited pvz2.
+func newPointServices(t *testing.T) *service.OrderService {
+	ts := newTestOrderService(t, testOptions{defaultPickupPoint: "pvz1"})
+	require.NoError(t, ts.points.CreatePickupPoint(context.Background(), &models.PickupPoint{ID: "pvz1", Address: "1 Main St", Capacity: 1}))
+	require.NoError(t, ts.points.CreatePickupPoint(context.Background(), &models.PickupPoint{ID: "pvz2", Address: "2 Main St"}))
+	return ts.orders
+}
+
+func TestAcceptOrderRefusesFullPickupPoint(t *testing.T) {
+	ctx := context.Background()
+	orders := newPointServices(t)
+
+	first := newOrderFor("ord1", "user1")
+	require.NoError(t, orders.CreateOrder(ctx, first))
+	assert.Equal(t, "pvz1", first.PickupPointID)
+	require.NoError(t, orders.CreateOrder(ctx, newOrderFor("ord2", "user1")))
+
+	require.NoError(t, orders.AcceptOrder(ctx, "ord1"))
+	assert.ErrorIs(t, orders.AcceptOrder(ctx, "ord2"), repository.ErrPickupPointFull)
+}
+
+func TestClerkSeesOwnPickupPoint(t *testing.T) {
+	ctx := context.Background()
+	orders := newPointServices(t)
+	require.NoError(t, orders.CreateOrder(ctx, newOrderFor("ord1", "user1")))
+	clerk := auth.WithPrincipal(ctx, auth.Principal{Name: "clerk", Role: auth.RoleClerk, PickupPointID: "pvz2"})
+
+	own := newOrderFor("ord2", "user1")
+	require.NoError(t, orders.CreateOrder(clerk, own))
+	assert.Equal(t, "pvz2", own.PickupPointID)
+	other := newOrderFor("ord3", "user1")
+	other.PickupPointID = "pvz1"
+	assert.Equal(t, apperr.Validation, apperr.KindOf(orders.CreateOrder(clerk, other)))
+
+	_, err := orders.GetOrderByID(clerk, "ord1")
+	assert.ErrorIs(t, err, repository.ErrOrderNotFound)
+	assert.ErrorIs(t, orders.DeliverOrder(clerk, "ord1"), repository.ErrOrderNotFound)
+
+	found, _, err := orders.SearchOrders(clerk, repository.OrderQuery{Sort: repository.OrderSort{Field: repository.SortByID}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "ord2", found[0].ID)
+	_, _, err = orders.SearchOrders(clerk, repository.OrderQuery{Filter: repository.OrderFilter{PickupPointID: "pvz1"}, Limit: 10})
+	assert.Equal(t, apperr.Validation, apperr.KindOf(err))
+
+	active, err := orders.ListActiveOrders(clerk)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	assert.Equal(t, "ord2", active[0].ID)
+}
+
+func TestCreateOrderRejectsStateTimestamps(t *testing.T) {
+	ctx := context.Background()
+	orders := newPointServices(t)
+
+	o := newOrderFor("ord1", "user1")
+	o.AcceptedAt = time.Now()
+	o.DeliveredAt = time.Now()
+	var e *apperr.Error
+	require.ErrorAs(t, orders.CreateOrder(ctx, o), &e)
+	assert.Equal(t, apperr.Validation, e.Kind)
+	assert.Equal(t, []apperr.FieldError{
+		apperr.Field("accepted_at", "must be empty: a new order starts as new"),
+		apperr.Field("delivered_at", "must be empty: a new order starts as new"),
+	}, e.Fields)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"unicode/utf8"
+
+	"homework/internal/apperr"
+	"homework/internal/config"
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{5,15}$`)
+
+const maxRecipientName = 200
+
+// RecipientService manages recipients and decides whether an order may be
+// addressed to one.
+type RecipientService struct {
+	repo       repository.RecipientRepository
+	timeouts   config.OperationTimeouts
+	autoCreate bool
+}
+
+// NewRecipientService returns a service that, with autoCreate set, creates
+// unknown recipients the first time an order names them.
+func NewRecipientService(repo repository.RecipientRepository, timeouts config.OperationTimeouts, autoCreate bool) *RecipientService {
+	return &RecipientService{repo: repo, timeouts: timeouts, autoCreate: autoCreate}
+}
+
+func (s *RecipientService) CreateRecipient(ctx context.Context, rc *models.Recipient) error {
+	if rc.Status == "" {
+		rc.Status = models.RecipientActive
+	}
+	if err := validateRecipient(rc); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	return s.repo.CreateRecipient(ctx, rc)
+}
+
+func (s *RecipientService) GetRecipient(ctx context.Context, id string) (*models.Recipient, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	return s.repo.GetRecipient(ctx, id)
+}
+
+func (s *RecipientService) ListRecipients(ctx context.Context, after string, limit int) ([]*models.Recipient, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	return s.repo.ListRecipients(ctx, after, limit)
+}
+
+func (s *RecipientService) UpdateRecipient(ctx context.Context, rc *models.Recipient) error {
+	if err := validateRecipient(rc); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	return s.repo.UpdateRecipient(ctx, rc)
+}
+
+func (s *RecipientService) DeleteRecipient(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	return s.repo.DeleteRecipient(ctx, id)
+}
+
+// resolve returns the recipient an order names. An unknown recipient is a
+// validation error unless auto-creation is on; then resolve returns nil and
+// the repository creates the recipient in the transaction that writes the
+// order, so a request that fails leaves no recipient behind.
+func (s *RecipientService) resolve(ctx context.Context, id string) (*models.Recipient, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	rc, err := s.repo.GetRecipient(ctx, id)
+	if errors.Is(err, repository.ErrRecipientNotFound) {
+		if s.autoCreate {
+			return nil, nil
+		}
+		return nil, apperr.NewValidation(apperr.Field("recipient_id", "unknown recipient %q", id))
+	}
+	return rc, err
+}
+
+// admit is resolve for a new order, which a blocked recipient may not get.
+func (s *RecipientService) admit(ctx context.Context, id string) error {
+	rc, err := s.resolve(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rc != nil && rc.Status == models.RecipientBlocked {
+		return fmt.Errorf("recipient %s: %w", id, models.ErrRecipientBlocked)
+	}
+	return nil
+}
+
+// validateRecipient reports every invalid field of rc. Contact details are
+// optional, since recipients created on the fly have none.
+func validateRecipient(rc *models.Recipient) error {
+	var fields []apperr.FieldError
+	if !idPattern.MatchString(rc.ID) {
+		fields = append(fields, apperr.Field("id", "must be 1-64 letters, digits, '-' or '_' and start with a letter or digit"))
+	}
+	if utf8.RuneCountInString(rc.Name) > maxRecipientName {
+		fields = append(fields, apperr.Field("name", "must be at most %d characters", maxRecipientName))
+	}
+	if rc.Phone != "" && !phonePattern.MatchString(rc.Phone) {
+		fields = append(fields, apperr.Field("phone", "must be 5-15 digits with an optional leading '+'"))
+	}
+	if rc.Email != "" && !validEmail(rc.Email) {
+		fields = append(fields, apperr.Field("email", "must be a plain email address"))
+	}
+	if !rc.Status.Valid() {
+		fields = append(fields, apperr.Field("status", "must be %q or %q", models.RecipientActive, models.RecipientBlocked))
+	}
+	if len(fields) > 0 {
+		return apperr.NewValidation(fields...)
+	}
+	return nil
+}
+
+// validEmail accepts a bare address, without a display name or brackets.
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+	"homework/internal/repository"
+	"homework/internal/service"
+)
+
+func newRecipientServices(t *testing.T, autoCreate bool) (*service.OrderService, *service.RecipientService) {
+	ts := newTestOrderService(t, testOptions{rejectUnknownRecipients: !autoCreate})
+	return ts.orders, ts.recipients
+}
+
+func newOrderFor(id, recipientID string) *models.Order {
+	return &models.Order{
+		ID:              id,
+		RecipientID:     recipientID,
+		StorageDeadline: time.Now().Add(24 * time.Hour),
+		Weight:          2,
+		Cost:            100,
+	}
+}
+
+func TestCreateOrderRecipients(t *testing.T) {
+	ctx := context.Background()
+	orders, recipients := newRecipientServices(t, true)
+
+	require.NoError(t, orders.CreateOrder(ctx, newOrderFor("ord1", "user1")))
+	rc, err := recipients.GetRecipient(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, models.RecipientActive, rc.Status)
+
+	rc.Status = models.RecipientBlocked
+	require.NoError(t, recipients.UpdateRecipient(ctx, rc))
+	err = orders.CreateOrder(ctx, newOrderFor("ord2", "user1"))
+	assert.ErrorIs(t, err, models.ErrRecipientBlocked)
+
+	orders, _ = newRecipientServices(t, false)
+	err = orders.CreateOrder(ctx, newOrderFor("ord3", "ghost"))
+	assert.Equal(t, apperr.Validation, apperr.KindOf(err))
+}
+
+func TestUpdateOrderCreatesRecipientOnlyWithTheOrder(t *testing.T) {
+	ctx := context.Background()
+	orders, recipients := newRecipientServices(t, true)
+	require.NoError(t, orders.CreateOrder(ctx, newOrderFor("ord1", "user1")))
+
+	o := newOrderFor("ord1", "user2")
+	err := orders.UpdateOrderIfVersion(ctx, o, 7)
+	assert.ErrorIs(t, err, repository.ErrVersionMismatch)
+	_, err = recipients.GetRecipient(ctx, "user2")
+	assert.ErrorIs(t, err, repository.ErrRecipientNotFound, "a failed update must not create the recipient")
+
+	require.NoError(t, orders.UpdateOrderIfVersion(ctx, o, 1))
+	_, err = recipients.GetRecipient(ctx, "user2")
+	assert.NoError(t, err)
+}
+
+func TestValidateRecipient(t *testing.T) {
+	_, recipients := newRecipientServices(t, true)
+	err := recipients.CreateRecipient(context.Background(), &models.Recipient{
+		ID: "user1", Phone: "call me", Email: "Ann <ann@example.com>", Status: "gone",
+	})
+	var e *apperr.Error
+	require.ErrorAs(t, err, &e)
+	fields := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		fields = append(fields, f.Field)
+	}
+	assert.Equal(t, []string{"phone", "email", "status"}, fields)
+}
@@ -1,29 +1,63 @@
 package service
 
 import (
+	"cmp"
+	"context"
 	"errors"
+	"fmt"
+	"time"
 
+	"homework/internal/apperr"
+	"homework/internal/auth"
 	"homework/internal/cache"
+	"homework/internal/config"
 	"homework/internal/models"
 	"homework/internal/repository"
 )
 
+// defaultReturnsLimit is the page size of ListReturns when none is given.
+const defaultReturnsLimit = 10
+
+// maxPatchAttempts bounds how often PatchOrder rereads an order that keeps
+// changing under an unguarded patch.
+const maxPatchAttempts = 3
+
 type OrderService struct {
 	repo         repository.Repository
 	activeCache  *cache.ActiveOrdersCache
 	historyCache *cache.HistoryCache
+	timeouts     config.OperationTimeouts
+	validator    *Validator
+	recipients   *RecipientService
+	points       *PickupPointService
 }
 
-func NewOrderService(repo repository.Repository, activeCache *cache.ActiveOrdersCache, historyCache *cache.HistoryCache) *OrderService {
+// NewOrderService returns the order service. Callers bound to a pickup point,
+// see auth.PickupPointScope, only see and operate on the orders of that point.
+func NewOrderService(repo repository.Repository, activeCache *cache.ActiveOrdersCache, historyCache *cache.HistoryCache, timeouts config.OperationTimeouts, validator *Validator, recipients *RecipientService, points *PickupPointService) *OrderService {
 	return &OrderService{
 		repo:         repo,
 		activeCache:  activeCache,
 		historyCache: historyCache,
+		timeouts:     timeouts,
+		validator:    validator,
+		recipients:   recipients,
+		points:       points,
+	}
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, d)
 }
 
-func (s *OrderService) RefreshActiveOrders() error {
-	orders, err := s.repo.List("", 1000, "")
+func (s *OrderService) RefreshActiveOrders(ctx context.Context) error {
+	defer cache.ObserveRefresh(cache.ActiveName, time.Now())
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	orders, err := s.repo.List(ctx, "", 1000, "")
 	if err != nil {
 		return err
 	}
@@ -40,20 +74,18 @@ func (s *OrderService) RefreshActiveOrders() error {
 	return nil
 }
 
-func (s *OrderService) GetOrderByID(id string) (*models.Order, error) {
-	s.activeCache.Mu.RLock()
-	order, ok := s.activeCache.Orders[id]
-	s.activeCache.Mu.RUnlock()
+func (s *OrderService) GetOrderByID(ctx context.Context, id string) (*models.Order, error) {
+	order, ok := s.activeCache.Get(id)
 	if ok {
+		if !visible(ctx, order) {
+			return nil, fmt.Errorf("order %s: %w", id, repository.ErrOrderNotFound)
+		}
 		return order, nil
 	}
-	order, err := s.repo.GetID(id)
+	order, err := s.lookup(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	if order == nil {
-		return nil, errors.New("order not found")
-	}
 	go func(o *models.Order) {
 		s.activeCache.Mu.Lock()
 		s.activeCache.Orders[o.ID] = o
@@ -62,8 +94,74 @@ func (s *OrderService) GetOrderByID(id string) (*models.Order, error) {
 	return order, nil
 }
 
-func (s *OrderService) CreateOrder(order *models.Order) error {
-	if err := s.repo.Create(order); err != nil {
+// lookup reads an order from the repository. An order outside the caller's
+// pickup point is reported as not found, like a missing one.
+func (s *OrderService) lookup(ctx context.Context, id string) (*models.Order, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	order, err := s.repo.GetID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil || !visible(ctx, order) {
+		return nil, fmt.Errorf("order %s: %w", id, repository.ErrOrderNotFound)
+	}
+	return order, nil
+}
+
+// authorize fails with repository.ErrOrderNotFound if the caller is bound
+// to a pickup point and the order is not there.
+func (s *OrderService) authorize(ctx context.Context, id string) error {
+	if auth.PickupPointScope(ctx) == "" {
+		return nil
+	}
+	_, err := s.GetOrderByID(ctx, id)
+	return err
+}
+
+// prepareUpdate checks an update against the stored order, which the caller
+// must be able to see, and settles its recipient and pickup point.
+func (s *OrderService) prepareUpdate(ctx context.Context, order *models.Order) error {
+	current, err := s.lookup(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.validator.ValidateUpdate(current, order); err != nil {
+		return err
+	}
+	if order.RecipientID != current.RecipientID {
+		if _, err := s.recipients.resolve(ctx, order.RecipientID); err != nil {
+			return err
+		}
+	}
+	return s.placeUpdate(ctx, current, order)
+}
+
+// placeUpdate keeps an updated order at its pickup point unless the update
+// names another one. An order on the shelf cannot move: its place is taken.
+func (s *OrderService) placeUpdate(ctx context.Context, current, order *models.Order) error {
+	if err := s.points.place(ctx, order, current.PickupPointID); err != nil {
+		return err
+	}
+	if current.OnShelf() && order.PickupPointID != current.PickupPointID {
+		return apperr.NewValidation(apperr.Field("pickup_point_id", "cannot change while the order is on the shelf"))
+	}
+	return nil
+}
+
+func (s *OrderService) CreateOrder(ctx context.Context, order *models.Order) error {
+	if err := s.validator.ValidateNew(order); err != nil {
+		return err
+	}
+	if err := s.recipients.admit(ctx, order.RecipientID); err != nil {
+		return err
+	}
+	if err := s.points.place(ctx, order, ""); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	if err := s.repo.Create(ctx, order); err != nil {
 		return err
 	}
 	s.activeCache.Mu.Lock()
@@ -72,8 +170,13 @@ func (s *OrderService) CreateOrder(order *models.Order) error {
 	return nil
 }
 
-func (s *OrderService) UpdateOrder(order *models.Order) error {
-	if err := s.repo.UpdateTx(order); err != nil {
+func (s *OrderService) UpdateOrder(ctx context.Context, order *models.Order) error {
+	if err := s.prepareUpdate(ctx, order); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	if err := s.repo.UpdateTx(ctx, order); err != nil {
 		return err
 	}
 	s.activeCache.Mu.Lock()
@@ -82,53 +185,132 @@ func (s *OrderService) UpdateOrder(order *models.Order) error {
 	return nil
 }
 
-func (s *OrderService) DeleteOrder(id string) error {
-	if err := s.repo.Delete(id); err != nil {
+// UpdateOrderIfVersion is UpdateOrder guarded by an optimistic lock: it
+// fails with repository.ErrVersionMismatch unless the stored order is
+// still at version.
+func (s *OrderService) UpdateOrderIfVersion(ctx context.Context, order *models.Order, version int64) error {
+	if err := s.prepareUpdate(ctx, order); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	if err := s.repo.UpdateIfVersion(ctx, order, version); err != nil {
 		return err
 	}
 	s.activeCache.Mu.Lock()
-	delete(s.activeCache.Orders, id)
+	s.activeCache.Orders[order.ID] = order
 	s.activeCache.Mu.Unlock()
 	return nil
 }
 
-func (s *OrderService) DeliverOrder(id string) error {
-	if err := s.repo.Deliver(id); err != nil {
-		return err
+// OrderVersion returns the version of order id as stored in the repository,
+// which the cache may lag behind.
+func (s *OrderService) OrderVersion(ctx context.Context, id string) (int64, error) {
+	order, err := s.lookup(ctx, id)
+	if err != nil {
+		return 0, err
 	}
-	order, err := s.repo.GetID(id)
+	return order.Version, nil
+}
+
+// PatchOrder applies patch to order id as stored in the repository, not as
+// cached, and writes the result guarded by version. Version 0 patches
+// whatever is stored: if a concurrent write slips in between, the order is
+// read and patched again rather than failing with a version mismatch.
+func (s *OrderService) PatchOrder(ctx context.Context, id string, version int64, patch func(*models.Order) error) (*models.Order, error) {
+	for attempt := 1; ; attempt++ {
+		order, err := s.lookup(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		expected := cmp.Or(version, order.Version)
+		if err := patch(order); err != nil {
+			return nil, err
+		}
+		err = s.UpdateOrderIfVersion(ctx, order, expected)
+		if version == 0 && attempt < maxPatchAttempts && errors.Is(err, repository.ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return order, nil
+	}
+}
+
+// RecipientOrders returns the recipient and their orders in the given
+// states, or in any state if states is empty.
+func (s *OrderService) RecipientOrders(ctx context.Context, id string, states []models.OrderState) (*models.Recipient, []*models.Order, error) {
+	rc, err := s.recipients.GetRecipient(ctx, id)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	if order != nil {
-		s.activeCache.Mu.Lock()
-		s.activeCache.Orders[id] = order
-		s.activeCache.Mu.Unlock()
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	orders := make([]*models.Order, 0)
+	f := repository.OrderFilter{States: states, RecipientID: id, PickupPointID: auth.PickupPointScope(ctx)}
+	err = s.repo.Export(ctx, f, func(o *models.Order) error {
+		orders = append(orders, o)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil
+	return rc, orders, nil
 }
 
-func (s *OrderService) ClientReturnOrder(id string) error {
-	if err := s.repo.ClientReturn(id); err != nil {
+// ExportOrders streams the orders matching f from the repository, bypassing
+// the caches. No operation timeout applies: an export runs for as long as the
+// caller keeps consuming rows.
+func (s *OrderService) ExportOrders(ctx context.Context, f repository.OrderFilter, fn func(*models.Order) error) error {
+	if err := scopeTo(ctx, &f.PickupPointID); err != nil {
 		return err
 	}
-	order, err := s.repo.GetID(id)
-	if err != nil {
+	return s.repo.Export(ctx, f, fn)
+}
+
+func (s *OrderService) DeleteOrder(ctx context.Context, id string) error {
+	if err := s.authorize(ctx, id); err != nil {
 		return err
 	}
-	if order != nil {
-		s.activeCache.Mu.Lock()
-		s.activeCache.Orders[id] = order
-		s.activeCache.Mu.Unlock()
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
 	}
+	s.activeCache.Mu.Lock()
+	delete(s.activeCache.Orders, id)
+	s.activeCache.Mu.Unlock()
 	return nil
 }
 
-func (s *OrderService) AcceptOrder(id string) error {
-	if err := s.repo.AcceptOrder(id); err != nil {
+func (s *OrderService) DeliverOrder(ctx context.Context, id string) error {
+	return s.transition(ctx, id, s.repo.Deliver)
+}
+
+func (s *OrderService) ClientReturnOrder(ctx context.Context, id string) error {
+	return s.transition(ctx, id, s.repo.ClientReturn)
+}
+
+func (s *OrderService) AcceptOrder(ctx context.Context, id string) error {
+	return s.transition(ctx, id, s.repo.AcceptOrder)
+}
+
+func (s *OrderService) CourierReturnOrder(ctx context.Context, id string) error {
+	return s.transition(ctx, id, s.repo.ReturnOrder)
+}
+
+// transition applies a repository state change and refreshes the cached order.
+func (s *OrderService) transition(ctx context.Context, id string, apply func(context.Context, string) error) error {
+	if err := s.authorize(ctx, id); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Transition)
+	defer cancel()
+	if err := apply(ctx, id); err != nil {
 		return err
 	}
-	order, err := s.repo.GetID(id)
+	order, err := s.repo.GetID(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -140,57 +322,97 @@ func (s *OrderService) AcceptOrder(id string) error {
 	return nil
 }
 
-func (s *OrderService) CourierReturnOrder(id string) error {
-	if err := s.repo.ReturnOrder(id); err != nil {
-		return err
+// ExpireOverdueOrders returns up to limit orders past their storage deadline
+// to the courier and refreshes them in the cache.
+func (s *OrderService) ExpireOverdueOrders(ctx context.Context, now time.Time, limit int) ([]*models.Order, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Transition)
+	defer cancel()
+	orders, err := s.repo.ExpireOverdue(ctx, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	s.activeCache.Mu.Lock()
+	for _, o := range orders {
+		s.activeCache.Orders[o.ID] = o
 	}
-	order, err := s.repo.GetID(id)
+	s.activeCache.Mu.Unlock()
+	return orders, nil
+}
+
+func (s *OrderService) OrderHistory(ctx context.Context, id string) ([]models.OrderEvent, error) {
+	if err := s.authorize(ctx, id); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	return s.repo.History(ctx, id)
+}
+
+// SearchOrders returns one page of q straight from the repository and
+// whether more orders follow it.
+func (s *OrderService) SearchOrders(ctx context.Context, q repository.OrderQuery) ([]*models.Order, bool, error) {
+	if err := scopeTo(ctx, &q.Filter.PickupPointID); err != nil {
+		return nil, false, err
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	limit := q.Limit
+	q.Limit++
+	orders, err := s.repo.Search(ctx, q)
 	if err != nil {
-		return err
+		return nil, false, err
 	}
-	if order != nil {
-		s.activeCache.Mu.Lock()
-		s.activeCache.Orders[id] = order
-		s.activeCache.Mu.Unlock()
+	if len(orders) > limit {
+		return orders[:limit], true, nil
 	}
-	return nil
+	return orders, false, nil
 }
 
-func (s *OrderService) ListActiveOrders() ([]*models.Order, error) {
+func (s *OrderService) ListActiveOrders(ctx context.Context) ([]*models.Order, error) {
 	s.activeCache.Mu.RLock()
 	defer s.activeCache.Mu.RUnlock()
 	orders := make([]*models.Order, 0, len(s.activeCache.Orders))
 	for _, o := range s.activeCache.Orders {
-		orders = append(orders, o)
+		if visible(ctx, o) {
+			orders = append(orders, o)
+		}
 	}
 	return orders, nil
 }
 
-func (s *OrderService) ListHistoryOrders() ([]*models.Order, error) {
-	return s.historyCache.Get(), nil
+func (s *OrderService) ListHistoryOrders(ctx context.Context) ([]*models.Order, error) {
+	orders := s.historyCache.Get()
+	if auth.PickupPointScope(ctx) == "" {
+		return orders, nil
+	}
+	scoped := make([]*models.Order, 0, len(orders))
+	for _, o := range orders {
+		if visible(ctx, o) {
+			scoped = append(scoped, o)
+		}
+	}
+	return scoped, nil
 }
 
-func (s *OrderService) ListReturns(offset, limit int64, recipientID string) ([]*models.Order, error) {
-	historyOrders := s.historyCache.Get()
-	var filtered []*models.Order
-	for _, o := range historyOrders {
-		if !o.ClientReturnAt.IsZero() {
-			if recipientID != "" && o.RecipientID != recipientID {
-				continue
-			}
-			filtered = append(filtered, o)
-		}
+// ListReturns returns one page of client returns straight from the
+// repository and whether more returns follow it.
+func (s *OrderService) ListReturns(ctx context.Context, q repository.ReturnsQuery) ([]*models.Order, bool, error) {
+	if err := scopeTo(ctx, &q.PickupPointID); err != nil {
+		return nil, false, err
 	}
-	if len(filtered) > 0 {
-		start := offset
-		if start > int64(len(filtered)) {
-			start = int64(len(filtered))
-		}
-		end := start + limit
-		if end > int64(len(filtered)) {
-			end = int64(len(filtered))
-		}
-		return filtered[start:end], nil
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	if q.Limit <= 0 {
+		q.Limit = defaultReturnsLimit
+	}
+	limit := q.Limit
+	q.Limit++
+	orders, err := s.repo.GetReturns(ctx, q)
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(orders)) > limit {
+		return orders[:limit], true, nil
 	}
-	return s.repo.GetReturns(offset, limit, recipientID)
+	return orders, false, nil
 }
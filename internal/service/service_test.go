@@ -0,0 +1,39 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/models"
+	"homework/internal/repository"
+)
+
+func TestPatchOrderIgnoresStaleCache(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestOrderService(t, testOptions{})
+	repo, orders := ts.repo, ts.orders
+	require.NoError(t, orders.CreateOrder(ctx, newOrderFor("ord1", "user1")))
+
+	// A write that bypasses the service leaves version 1 in the cache.
+	stored, err := repo.GetID(ctx, "ord1")
+	require.NoError(t, err)
+	stored.Cost = 300
+	require.NoError(t, repo.UpdateTx(ctx, stored))
+
+	setWeight := func(o *models.Order) error {
+		o.Weight = 5
+		return nil
+	}
+	o, err := orders.PatchOrder(ctx, "ord1", 0, setWeight)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), o.Version)
+	assert.Equal(t, 300.0, o.Cost, "the patch must apply to the stored order")
+
+	_, err = orders.PatchOrder(ctx, "ord1", 2, setWeight)
+	assert.ErrorIs(t, err, repository.ErrVersionMismatch)
+	_, err = orders.PatchOrder(ctx, "ord1", 3, setWeight)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+	"homework/internal/packaging"
+)
+
+// idPattern is the accepted format of order and recipient IDs.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// Validator checks the invariants of an order payload before it reaches the
+// repository. Unlike the repository, which stops at the first problem, it
+// reports every violation at once.
+type Validator struct {
+	maxHorizon time.Duration
+	packaging  *packaging.Registry
+	now        func() time.Time
+}
+
+// NewValidator returns a validator that accepts storage deadlines up to
+// maxHorizon ahead; zero disables the upper bound.
+func NewValidator(maxHorizon time.Duration, registry *packaging.Registry) *Validator {
+	return &Validator{maxHorizon: maxHorizon, packaging: registry, now: time.Now}
+}
+
+// Validate returns an apperr validation error listing every invalid field of
+// o, or nil.
+func (v *Validator) Validate(o *models.Order) error {
+	return collect(o, v.checkIDs, v.checkAmounts, v.checkDeadline, v.checkPackaging)
+}
+
+// ValidateNew is Validate for an order about to be created, which must also
+// start in the new state: state timestamps are set only by transitions.
+func (v *Validator) ValidateNew(o *models.Order) error {
+	return collect(o, v.checkIDs, v.checkAmounts, v.checkDeadline, v.checkPackaging, checkNoState)
+}
+
+// ValidateUpdate is Validate for an update of current. The storage deadline
+// of a stored order may lie in the past, once it has expired or been handed
+// out, so it is held to the future and the horizon only when it changes.
+func (v *Validator) ValidateUpdate(current, o *models.Order) error {
+	deadline := v.checkDeadline
+	if o.StorageDeadline.Equal(current.StorageDeadline) {
+		deadline = checkDeadlineSet
+	}
+	return collect(o, v.checkIDs, v.checkAmounts, deadline, v.checkPackaging)
+}
+
+// collect gathers the problems found by every check into one error.
+func collect(o *models.Order, checks ...func(*models.Order) []apperr.FieldError) error {
+	var fields []apperr.FieldError
+	for _, check := range checks {
+		fields = append(fields, check(o)...)
+	}
+	if len(fields) > 0 {
+		return apperr.NewValidation(fields...)
+	}
+	return nil
+}
+
+func checkNoState(o *models.Order) []apperr.FieldError {
+	stamps := []struct {
+		field string
+		at    time.Time
+	}{
+		{"accepted_at", o.AcceptedAt},
+		{"delivered_at", o.DeliveredAt},
+		{"returned_at", o.ReturnedAt},
+		{"client_return_at", o.ClientReturnAt},
+	}
+	var fields []apperr.FieldError
+	for _, s := range stamps {
+		if !s.at.IsZero() {
+			fields = append(fields, apperr.Field(s.field, "must be empty: a new order starts as new"))
+		}
+	}
+	return fields
+}
+
+func (v *Validator) checkIDs(o *models.Order) []apperr.FieldError {
+	var fields []apperr.FieldError
+	if !idPattern.MatchString(o.ID) {
+		fields = append(fields, apperr.Field("id", "must be 1-64 letters, digits, '-' or '_' and start with a letter or digit"))
+	}
+	if !idPattern.MatchString(o.RecipientID) {
+		fields = append(fields, apperr.Field("recipient_id", "must be 1-64 letters, digits, '-' or '_' and start with a letter or digit"))
+	}
+	return fields
+}
+
+func (v *Validator) checkAmounts(o *models.Order) []apperr.FieldError {
+	var fields []apperr.FieldError
+	if o.Weight <= 0 {
+		fields = append(fields, apperr.Field("weight", "must be positive"))
+	}
+	if o.Cost <= 0 {
+		fields = append(fields, apperr.Field("cost", "must be positive"))
+	}
+	return fields
+}
+
+func (v *Validator) checkDeadline(o *models.Order) []apperr.FieldError {
+	now := v.now()
+	switch {
+	case o.StorageDeadline.IsZero():
+		return checkDeadlineSet(o)
+	case !o.StorageDeadline.After(now):
+		return []apperr.FieldError{apperr.Field("storage_deadline", "must be in the future")}
+	case v.maxHorizon > 0 && o.StorageDeadline.After(now.Add(v.maxHorizon)):
+		return []apperr.FieldError{apperr.Field("storage_deadline", "must be within %s from now", v.maxHorizon)}
+	}
+	return nil
+}
+
+func checkDeadlineSet(o *models.Order) []apperr.FieldError {
+	if o.StorageDeadline.IsZero() {
+		return []apperr.FieldError{apperr.Field("storage_deadline", "is required")}
+	}
+	return nil
+}
+
+// checkPackaging resolves the packaging types and, once the weight is known
+// to be valid, checks it against their limits.
+func (v *Validator) checkPackaging(o *models.Order) []apperr.FieldError {
+	var err error
+	if o.Weight > 0 {
+		_, err = v.packaging.FinalCost(o.Packaging, o.Weight, o.Cost)
+	} else {
+		_, err = v.packaging.Resolve(o.Packaging)
+	}
+	if err == nil {
+		return nil
+	}
+	msg := strings.TrimPrefix(err.Error(), packaging.ErrInvalidPackaging.Error()+": ")
+	return []apperr.FieldError{apperr.Field("packaging", "%s", msg)}
+}
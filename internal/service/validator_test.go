@@ -0,0 +1,76 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework/internal/apperr"
+	"homework/internal/models"
+	"homework/internal/packaging"
+	"homework/internal/service"
+)
+
+func TestValidator(t *testing.T) {
+	v := service.NewValidator(30*24*time.Hour, packaging.Default)
+
+	valid := &models.Order{
+		ID:              "order-1",
+		RecipientID:     "user_42",
+		StorageDeadline: time.Now().Add(24 * time.Hour),
+		Weight:          3,
+		Cost:            100,
+		Packaging:       packaging.Packaging{"box", "film"},
+	}
+	assert.NoError(t, v.Validate(valid))
+
+	invalid := &models.Order{
+		ID:              "",
+		RecipientID:     "user 42",
+		StorageDeadline: time.Now().Add(-time.Hour),
+		Weight:          -1,
+		Cost:            0,
+		Packaging:       packaging.Packaging{"crate"},
+	}
+	err := v.Validate(invalid)
+	var appErr *apperr.Error
+	require.True(t, errors.As(err, &appErr))
+	assert.Equal(t, apperr.Validation, appErr.Kind)
+
+	var fields []string
+	for _, f := range appErr.Fields {
+		fields = append(fields, f.Field)
+	}
+	assert.Equal(t, []string{"id", "recipient_id", "weight", "cost", "storage_deadline", "packaging"}, fields)
+
+	tooFar := *valid
+	tooFar.StorageDeadline = time.Now().Add(60 * 24 * time.Hour)
+	assert.Equal(t, apperr.Validation, apperr.KindOf(v.Validate(&tooFar)))
+
+	tooHeavy := *valid
+	tooHeavy.Packaging = packaging.Packaging{"bag"}
+	tooHeavy.Weight = 50
+	assert.Equal(t, apperr.Validation, apperr.KindOf(v.Validate(&tooHeavy)))
+}
+
+func TestUpdateOrderWithPastDeadline(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestOrderService(t, testOptions{})
+	repo, svc := ts.repo, ts.orders
+	deadline := time.Now().Add(-time.Hour)
+	require.NoError(t, repo.Create(ctx, &models.Order{
+		ID: "ord1", RecipientID: "user1", StorageDeadline: deadline, Weight: 2, Cost: 100,
+	}))
+
+	o, err := repo.GetID(ctx, "ord1")
+	require.NoError(t, err)
+	o.Cost = 150
+	require.NoError(t, svc.UpdateOrder(ctx, o))
+
+	o.StorageDeadline = deadline.Add(-time.Hour)
+	assert.Equal(t, apperr.Validation, apperr.KindOf(svc.UpdateOrder(ctx, o)))
+}
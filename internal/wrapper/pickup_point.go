@@ -0,0 +1,23 @@
+package wrapper
+
+import (
+	"context"
+
+	"homework/internal/models"
+)
+
+func (w *OrderWrapper) CreatePickupPoint(ctx context.Context, p *models.PickupPoint) error {
+	return w.pointService.CreatePickupPoint(ctx, p)
+}
+
+func (w *OrderWrapper) GetPickupPoint(ctx context.Context, id string) (*models.PickupPoint, error) {
+	return w.pointService.GetPickupPoint(ctx, id)
+}
+
+func (w *OrderWrapper) ListPickupPoints(ctx context.Context) ([]*models.PickupPoint, error) {
+	return w.pointService.ListPickupPoints(ctx)
+}
+
+func (w *OrderWrapper) UpdatePickupPoint(ctx context.Context, p *models.PickupPoint) error {
+	return w.pointService.UpdatePickupPoint(ctx, p)
+}
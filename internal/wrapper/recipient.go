@@ -0,0 +1,31 @@
+package wrapper
+
+import (
+	"context"
+
+	"homework/internal/models"
+)
+
+func (w *OrderWrapper) CreateRecipient(ctx context.Context, rc *models.Recipient) error {
+	return w.recipientService.CreateRecipient(ctx, rc)
+}
+
+func (w *OrderWrapper) GetRecipient(ctx context.Context, id string) (*models.Recipient, error) {
+	return w.recipientService.GetRecipient(ctx, id)
+}
+
+func (w *OrderWrapper) ListRecipients(ctx context.Context, after string, limit int) ([]*models.Recipient, error) {
+	return w.recipientService.ListRecipients(ctx, after, limit)
+}
+
+func (w *OrderWrapper) UpdateRecipient(ctx context.Context, rc *models.Recipient) error {
+	return w.recipientService.UpdateRecipient(ctx, rc)
+}
+
+func (w *OrderWrapper) DeleteRecipient(ctx context.Context, id string) error {
+	return w.recipientService.DeleteRecipient(ctx, id)
+}
+
+func (w *OrderWrapper) RecipientOrders(ctx context.Context, id string, states []models.OrderState) (*models.Recipient, []*models.Order, error) {
+	return w.orderService.RecipientOrders(ctx, id, states)
+}
@@ -1,76 +1,105 @@
 package wrapper
 
 import (
+	"context"
+	"time"
+
 	"homework/internal/models"
+	"homework/internal/orderio"
+	"homework/internal/repository"
 	"homework/internal/service"
 )
 
 type OrderWrapper struct {
-	orderService *service.OrderService
+	orderService     *service.OrderService
+	recipientService *service.RecipientService
+	pointService     *service.PickupPointService
 }
 
-func NewOrderWrapper(svc *service.OrderService) *OrderWrapper {
+func NewOrderWrapper(svc *service.OrderService, recipients *service.RecipientService, points *service.PickupPointService) *OrderWrapper {
 	return &OrderWrapper{
-		orderService: svc,
+		orderService:     svc,
+		recipientService: recipients,
+		pointService:     points,
 	}
 }
 
-func (w *OrderWrapper) GetOrderByID(id string) (*models.Order, error) {
-	return w.orderService.GetOrderByID(id)
+func (w *OrderWrapper) GetOrderByID(ctx context.Context, id string) (*models.Order, error) {
+	return w.orderService.GetOrderByID(ctx, id)
 }
 
-func (w *OrderWrapper) CreateOrder(order *models.Order) error {
-	return w.orderService.CreateOrder(order)
+func (w *OrderWrapper) CreateOrder(ctx context.Context, order *models.Order) error {
+	return w.orderService.CreateOrder(ctx, order)
 }
 
-func (w *OrderWrapper) UpdateOrder(order *models.Order) error {
-	return w.orderService.UpdateOrder(order)
+func (w *OrderWrapper) ImportOrders(ctx context.Context, rows []orderio.Row, mode service.BatchMode) (*service.BatchReport, error) {
+	return w.orderService.ImportOrders(ctx, rows, mode)
 }
 
-func (w *OrderWrapper) DeleteOrder(id string) error {
-	return w.orderService.DeleteOrder(id)
+func (w *OrderWrapper) ExportOrders(ctx context.Context, f repository.OrderFilter, fn func(*models.Order) error) error {
+	return w.orderService.ExportOrders(ctx, f, fn)
 }
 
-func (w *OrderWrapper) DeliverOrder(id string) error {
-	return w.orderService.DeliverOrder(id)
+func (w *OrderWrapper) UpdateOrder(ctx context.Context, order *models.Order) error {
+	return w.orderService.UpdateOrder(ctx, order)
 }
 
-func (w *OrderWrapper) ClientReturnOrder(id string) error {
-	return w.orderService.ClientReturnOrder(id)
+func (w *OrderWrapper) UpdateOrderIfVersion(ctx context.Context, order *models.Order, version int64) error {
+	return w.orderService.UpdateOrderIfVersion(ctx, order, version)
 }
 
-func (w *OrderWrapper) AcceptOrder(id string) error {
-	return w.orderService.AcceptOrder(id)
+func (w *OrderWrapper) OrderVersion(ctx context.Context, id string) (int64, error) {
+	return w.orderService.OrderVersion(ctx, id)
 }
 
-func (w *OrderWrapper) CourierReturnOrder(id string) error {
-	return w.orderService.CourierReturnOrder(id)
+func (w *OrderWrapper) PatchOrder(ctx context.Context, id string, version int64, patch func(*models.Order) error) (*models.Order, error) {
+	return w.orderService.PatchOrder(ctx, id, version, patch)
 }
 
-func (w *OrderWrapper) RefreshActiveOrders() error {
-	return w.orderService.RefreshActiveOrders()
+func (w *OrderWrapper) DeleteOrder(ctx context.Context, id string) error {
+	return w.orderService.DeleteOrder(ctx, id)
 }
 
-func (w *OrderWrapper) ListActiveOrders() ([]*models.Order, error) {
-	return w.orderService.ListActiveOrders()
+func (w *OrderWrapper) DeliverOrder(ctx context.Context, id string) error {
+	return w.orderService.DeliverOrder(ctx, id)
 }
 
-func (w *OrderWrapper) ListHistoryOrders() ([]*models.Order, error) {
-	return w.orderService.ListHistoryOrders()
+func (w *OrderWrapper) ClientReturnOrder(ctx context.Context, id string) error {
+	return w.orderService.ClientReturnOrder(ctx, id)
 }
 
-func (w *OrderWrapper) GetReturns(offset, limit int64, recipientID string) ([]*models.Order, error) {
-	orders, err := w.orderService.ListReturns(offset, limit, recipientID)
-	if err != nil {
-		return nil, err
-	}
-	if len(orders) == 0 {
-		go func() {
-			err := w.orderService.RefreshActiveOrders()
-			if err != nil {
-				return
-			}
-		}()
-	}
-	return orders, nil
+func (w *OrderWrapper) AcceptOrder(ctx context.Context, id string) error {
+	return w.orderService.AcceptOrder(ctx, id)
+}
+
+func (w *OrderWrapper) CourierReturnOrder(ctx context.Context, id string) error {
+	return w.orderService.CourierReturnOrder(ctx, id)
+}
+
+func (w *OrderWrapper) ExpireOverdueOrders(ctx context.Context, now time.Time, limit int) ([]*models.Order, error) {
+	return w.orderService.ExpireOverdueOrders(ctx, now, limit)
+}
+
+func (w *OrderWrapper) OrderHistory(ctx context.Context, id string) ([]models.OrderEvent, error) {
+	return w.orderService.OrderHistory(ctx, id)
+}
+
+func (w *OrderWrapper) RefreshActiveOrders(ctx context.Context) error {
+	return w.orderService.RefreshActiveOrders(ctx)
+}
+
+func (w *OrderWrapper) SearchOrders(ctx context.Context, q repository.OrderQuery) ([]*models.Order, bool, error) {
+	return w.orderService.SearchOrders(ctx, q)
+}
+
+func (w *OrderWrapper) ListActiveOrders(ctx context.Context) ([]*models.Order, error) {
+	return w.orderService.ListActiveOrders(ctx)
+}
+
+func (w *OrderWrapper) ListHistoryOrders(ctx context.Context) ([]*models.Order, error) {
+	return w.orderService.ListHistoryOrders(ctx)
+}
+
+func (w *OrderWrapper) GetReturns(ctx context.Context, q repository.ReturnsQuery) ([]*models.Order, bool, error) {
+	return w.orderService.ListReturns(ctx, q)
 }
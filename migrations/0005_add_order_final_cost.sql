@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE orders
+    ADD COLUMN final_cost DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+UPDATE orders o
+SET final_cost = o.cost + COALESCE((SELECT SUM(CASE p.pkg_value
+                                                   WHEN 'bag' THEN 5
+                                                   WHEN 'box' THEN 20
+                                                   WHEN 'film' THEN 1
+                                                   ELSE 0 END)
+                                    FROM order_packaging p
+                                    WHERE p.order_id = o.id), 0);
+
+-- +goose Down
+ALTER TABLE orders
+    DROP COLUMN final_cost;
@@ -0,0 +1,7 @@
+-- +goose Up
+UPDATE orders SET accepted_at = NULL WHERE accepted_at = '0001-01-01 00:00:00+00';
+UPDATE orders SET delivered_at = NULL WHERE delivered_at = '0001-01-01 00:00:00+00';
+UPDATE orders SET returned_at = NULL WHERE returned_at = '0001-01-01 00:00:00+00';
+UPDATE orders SET client_return_at = NULL WHERE client_return_at = '0001-01-01 00:00:00+00';
+
+-- +goose Down
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE order_events
+(
+    id         BIGSERIAL PRIMARY KEY,
+    order_id   TEXT        NOT NULL,
+    event_type TEXT        NOT NULL,
+    old_state  TEXT        NOT NULL DEFAULT '',
+    new_state  TEXT        NOT NULL DEFAULT '',
+    changes    JSONB       NOT NULL DEFAULT '[]',
+    actor      TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX order_events_order_id_idx ON order_events (order_id, id);
+
+-- +goose Down
+DROP TABLE order_events;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE api_keys
+(
+    id           BIGSERIAL PRIMARY KEY,
+    name         TEXT        NOT NULL,
+    prefix       TEXT        NOT NULL UNIQUE,
+    key_hash     TEXT        NOT NULL,
+    scopes       TEXT[]      NOT NULL,
+    created_by   TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+-- +goose Down
+DROP TABLE api_keys;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE idempotency_keys
+(
+    principal    TEXT        NOT NULL,
+    key          TEXT        NOT NULL,
+    request_hash TEXT        NOT NULL,
+    completed    BOOLEAN     NOT NULL DEFAULT FALSE,
+    status_code  INT         NOT NULL DEFAULT 0,
+    content_type TEXT        NOT NULL DEFAULT '',
+    body         BYTEA,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (principal, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
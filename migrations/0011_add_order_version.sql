@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE orders ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE orders DROP COLUMN version;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Keyset pagination on GET /orders walks (sort column, id).
+CREATE INDEX orders_recipient_id_idx ON orders (recipient_id, id);
+CREATE INDEX orders_storage_deadline_idx ON orders (storage_deadline, id);
+CREATE INDEX orders_last_state_change_idx ON orders (last_state_change, id);
+CREATE INDEX orders_weight_idx ON orders (weight, id);
+CREATE INDEX orders_cost_idx ON orders (cost, id);
+CREATE INDEX orders_accepted_at_idx ON orders (accepted_at) WHERE accepted_at IS NOT NULL;
+CREATE INDEX orders_delivered_at_idx ON orders (delivered_at) WHERE delivered_at IS NOT NULL;
+-- The primary key of order_packaging starts with order_id; this one serves
+-- the packaging type filter.
+CREATE INDEX order_packaging_pkg_value_idx ON order_packaging (pkg_value, order_id);
+
+-- +goose Down
+DROP INDEX order_packaging_pkg_value_idx;
+DROP INDEX orders_delivered_at_idx;
+DROP INDEX orders_accepted_at_idx;
+DROP INDEX orders_cost_idx;
+DROP INDEX orders_weight_idx;
+DROP INDEX orders_last_state_change_idx;
+DROP INDEX orders_storage_deadline_idx;
+DROP INDEX orders_recipient_id_idx;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Keyset pagination on GET /returns walks (client_return_at, id) over
+-- returned orders only; recipient_id is included so the recipient filter is
+-- checked from the index.
+CREATE INDEX orders_client_return_idx ON orders (client_return_at, id)
+    INCLUDE (recipient_id) WHERE client_return_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX orders_client_return_idx;
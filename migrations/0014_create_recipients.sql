@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE recipients
+(
+    id         TEXT PRIMARY KEY,
+    name       TEXT        NOT NULL DEFAULT '',
+    phone      TEXT        NOT NULL DEFAULT '',
+    email      TEXT        NOT NULL DEFAULT '',
+    status     TEXT        NOT NULL DEFAULT 'active',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Every recipient already referenced by an order gets a bare row, so the
+-- foreign key can be added to existing data.
+INSERT INTO recipients (id)
+SELECT DISTINCT recipient_id FROM orders;
+
+ALTER TABLE orders
+    ADD CONSTRAINT orders_recipient_fk FOREIGN KEY (recipient_id)
+        REFERENCES recipients (id);
+
+-- +goose Down
+ALTER TABLE orders DROP CONSTRAINT orders_recipient_fk;
+DROP TABLE recipients;
@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE pickup_points
+(
+    id            TEXT PRIMARY KEY,
+    address       TEXT        NOT NULL,
+    working_hours TEXT        NOT NULL DEFAULT '',
+    capacity      INTEGER     NOT NULL DEFAULT 0 CHECK (capacity >= 0),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Until now the service modelled a single, unlimited pickup point. Its
+-- orders move to an explicit one, which is also APP_DEFAULT_PICKUP_POINT.
+INSERT INTO pickup_points (id, address)
+VALUES ('default', '');
+
+ALTER TABLE orders
+    ADD COLUMN pickup_point_id TEXT
+        CONSTRAINT orders_pickup_point_fk REFERENCES pickup_points (id);
+
+UPDATE orders SET pickup_point_id = 'default';
+
+-- Scoped listings and the capacity check select orders by point.
+CREATE INDEX orders_pickup_point_idx ON orders (pickup_point_id, id);
+
+-- +goose Down
+DROP INDEX orders_pickup_point_idx;
+ALTER TABLE orders DROP COLUMN pickup_point_id;
+DROP TABLE pickup_points;